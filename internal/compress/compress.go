@@ -8,6 +8,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
 )
 
 func copyToWriter(filename string, w io.Writer) error {
@@ -21,7 +25,47 @@ func copyToWriter(filename string, w io.Writer) error {
 	return err
 }
 
+// Plan records the creation of an archive on dry-run, when callers must not
+// create the archive file at all.
+func Plan(format string, chdir string, prefix string, entries []string) {
+	files := []string{}
+	for _, entry := range entries {
+		files = append(files, fmt.Sprintf("%s/%s", prefix, entry))
+	}
+	plan.Record(&plan.Operation{
+		Type:  "archive",
+		Args:  []string{format, prefix},
+		Dir:   chdir,
+		Files: files,
+	})
+}
+
+// normalizedMode keeps only the executable bits of mode, that are the only
+// permissions that matter for archived files.
+func normalizedMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
 func TarGzip(chdir string, prefix string, entries []string, out io.Writer) error {
+	return tarGzip(chdir, prefix, entries, nil, out)
+}
+
+// TarGzipReproducible works like TarGzip, but entries are sorted and their
+// timestamps, ownership and permissions are normalized, to produce the same
+// archive from the same files.
+func TarGzipReproducible(chdir string, prefix string, entries []string, mtime time.Time, out io.Writer) error {
+	return tarGzip(chdir, prefix, entries, &mtime, out)
+}
+
+func tarGzip(chdir string, prefix string, entries []string, mtime *time.Time, out io.Writer) error {
+	if mtime != nil {
+		entries = append([]string{}, entries...)
+		sort.Strings(entries)
+	}
+
 	gz := gzip.NewWriter(out)
 	defer gz.Close()
 	tw := tar.NewWriter(gz)
@@ -47,6 +91,16 @@ func TarGzip(chdir string, prefix string, entries []string, out io.Writer) error
 
 		hdr.Name = hdrName
 
+		if mtime != nil {
+			hdr = &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     hdrName,
+				Size:     info.Size(),
+				Mode:     int64(normalizedMode(info.Mode())),
+				ModTime:  mtime.UTC().Truncate(time.Second),
+			}
+		}
+
 		if err := tw.WriteHeader(hdr); err != nil {
 			return err
 		}
@@ -60,6 +114,20 @@ func TarGzip(chdir string, prefix string, entries []string, out io.Writer) error
 }
 
 func Zip(chdir string, prefix string, entries []string, out io.Writer) error {
+	return zipFiles(chdir, prefix, entries, nil, out)
+}
+
+// ZipReproducible is the Zip counterpart of TarGzipReproducible.
+func ZipReproducible(chdir string, prefix string, entries []string, mtime time.Time, out io.Writer) error {
+	return zipFiles(chdir, prefix, entries, &mtime, out)
+}
+
+func zipFiles(chdir string, prefix string, entries []string, mtime *time.Time, out io.Writer) error {
+	if mtime != nil {
+		entries = append([]string{}, entries...)
+		sort.Strings(entries)
+	}
+
 	zw := zip.NewWriter(out)
 	defer zw.Close()
 
@@ -75,7 +143,16 @@ func Zip(chdir string, prefix string, entries []string, out io.Writer) error {
 			continue
 		}
 
-		f, err := zw.Create(fmt.Sprintf("%s/%s", prefix, entry))
+		hdr := &zip.FileHeader{
+			Name:   fmt.Sprintf("%s/%s", prefix, entry),
+			Method: zip.Deflate,
+		}
+		if mtime != nil {
+			hdr.Modified = mtime.UTC().Truncate(time.Second)
+			hdr.SetMode(normalizedMode(info.Mode()))
+		}
+
+		f, err := zw.CreateHeader(hdr)
 		if err != nil {
 			return err
 		}
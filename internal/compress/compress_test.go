@@ -0,0 +1,85 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFiles creates the same files in a new directory, with mtimes and
+// modes that depend on seed.
+func writeFiles(t *testing.T, seed int) string {
+	dir, err := ioutil.TempDir("", "yatr-compress-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{"foo", "foo binary", 0700 + os.FileMode(seed*0055)},
+		{"license.txt", "license", 0600 + os.FileMode(seed*0044)},
+		{"readme.txt", "readme", 0640},
+	}
+	for i, f := range files {
+		fn := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(fn, []byte(f.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(fn, f.mode); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Date(2000+seed, 1, 1, 0, 0, i, 123, time.UTC)
+		if err := os.Chtimes(fn, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReproducible(t *testing.T) {
+	mtime := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		name     string
+		compress func(chdir string, prefix string, entries []string, mtime time.Time, out *bytes.Buffer) error
+	}{
+		{"tar.gz", func(chdir string, prefix string, entries []string, mtime time.Time, out *bytes.Buffer) error {
+			return TarGzipReproducible(chdir, prefix, entries, mtime, out)
+		}},
+		{"zip", func(chdir string, prefix string, entries []string, mtime time.Time, out *bytes.Buffer) error {
+			return ZipReproducible(chdir, prefix, entries, mtime, out)
+		}},
+	}
+
+	dir1 := writeFiles(t, 0)
+	defer os.RemoveAll(dir1)
+	dir2 := writeFiles(t, 1)
+	defer os.RemoveAll(dir2)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out1, out2 bytes.Buffer
+
+			// entries in different order must produce the same archive
+			if err := test.compress(dir1, "foo-1.0", []string{"foo", "license.txt", "readme.txt"}, mtime, &out1); err != nil {
+				t.Fatal(err)
+			}
+			if err := test.compress(dir2, "foo-1.0", []string{"readme.txt", "foo", "license.txt"}, mtime, &out2); err != nil {
+				t.Fatal(err)
+			}
+
+			if out1.Len() == 0 {
+				t.Fatal("empty archive")
+			}
+			if !bytes.Equal(out1.Bytes(), out2.Bytes()) {
+				t.Errorf("archives differ")
+			}
+		})
+	}
+}
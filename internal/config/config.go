@@ -1,39 +1,514 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/rafaelmartins/yatr/internal/types"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
+	Include              []string          `yaml:"include"`
+	Runner               string            `yaml:"runner"`
 	DefaultConfigureArgs []string          `yaml:"default_configure_args"`
 	DefaultTaskArgs      []string          `yaml:"default_task_args"`
+	Variables            map[string]string `yaml:"variables"`
+	Golang               types.Golang      `yaml:"golang"`
 	Targets              map[string]Target `yaml:"targets"`
+
+	// raw target definitions, used to resolve inheritance. configs built in
+	// Go don't have them, see rawTargetsFromTargets
+	rawTargets map[string]rawMap
 }
 
+type rawMap map[interface{}]interface{}
+
 type Target struct {
-	ConfigureArgs        []string `yaml:"configure_args"`
-	TaskArgs             []string `yaml:"task_args"`
-	TaskScript           string   `yaml:"task_script"`
-	ArchiveFilter        string   `yaml:"archive_filter"`
-	ArchiveExtractFilter string   `yaml:"archive_extract_filter"`
-	PublishOnFailure     bool     `yaml:"publish_on_failure"`
+	Extends              string            `yaml:"extends,omitempty"`
+	Runner               string            `yaml:"runner,omitempty"`
+	Variables            map[string]string `yaml:"variables,omitempty"`
+	ConfigureArgs        []string          `yaml:"configure_args,omitempty"`
+	TaskArgs             []string          `yaml:"task_args,omitempty"`
+	TaskScript           string            `yaml:"task_script,omitempty"`
+	ArchiveFilter        string            `yaml:"archive_filter,omitempty"`
+	ArchiveExtractFilter string            `yaml:"archive_extract_filter,omitempty"`
+	PublishOnFailure     bool              `yaml:"publish_on_failure,omitempty"`
+	Hooks                Hooks             `yaml:"hooks,omitempty"`
 }
 
-func Read(filename string) (*Config, error) {
-	conf := &Config{}
+// Hook is a script executed before or after a pipeline step, like
+// task_script. OnFailure may be `abort` (default), `warn` or `ignore`.
+type Hook struct {
+	Script    string   `yaml:"script,omitempty"`
+	Args      []string `yaml:"args,omitempty"`
+	OnFailure string   `yaml:"on_failure,omitempty"`
+}
+
+type Hooks struct {
+	PreConfigure  *Hook `yaml:"pre_configure,omitempty"`
+	PostConfigure *Hook `yaml:"post_configure,omitempty"`
+	PreTask       *Hook `yaml:"pre_task,omitempty"`
+	PostTask      *Hook `yaml:"post_task,omitempty"`
+	PreCollect    *Hook `yaml:"pre_collect,omitempty"`
+	PostCollect   *Hook `yaml:"post_collect,omitempty"`
+	PrePublish    *Hook `yaml:"pre_publish,omitempty"`
+	PostPublish   *Hook `yaml:"post_publish,omitempty"`
+}
+
+func (h *Hooks) all() map[string]*Hook {
+	return map[string]*Hook{
+		"pre_configure":  h.PreConfigure,
+		"post_configure": h.PostConfigure,
+		"pre_task":       h.PreTask,
+		"post_task":      h.PostTask,
+		"pre_collect":    h.PreCollect,
+		"post_collect":   h.PostCollect,
+		"pre_publish":    h.PrePublish,
+		"post_publish":   h.PostPublish,
+	}
+}
+
+func toRawMap(v interface{}) rawMap {
+	rv := rawMap{}
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		for k, v := range m {
+			rv[k] = v
+		}
+	}
+	if m, ok := v.(rawMap); ok {
+		for k, v := range m {
+			rv[k] = v
+		}
+	}
+	return rv
+}
+
+// mergeKeys merges the keys of src on top of the keys of dst.
+func mergeKeys(dst interface{}, src interface{}) rawMap {
+	rv := toRawMap(dst)
+	for k, v := range toRawMap(src) {
+		rv[k] = v
+	}
+	return rv
+}
+
+// mergeTarget merges the keys of a target definition on top of base. Every
+// key replaces the base value entirely, including lists, except for
+// `variables`, that are merged key by key, and `hooks`, that are merged
+// stage by stage, each stage replacing the base one entirely.
+func mergeTarget(base rawMap, target rawMap) rawMap {
+	rv := toRawMap(base)
+	for k, v := range target {
+		switch k {
+		case "variables", "hooks":
+			rv[k] = mergeKeys(rv[k], v)
+		default:
+			rv[k] = v
+		}
+	}
+	return rv
+}
+
+// mergeConfig merges a config file on top of dst. Top level keys replace
+// the dst values, except for `variables` and `golang`, that are merged key
+// by key, and `targets`, that are merged target by target, with
+// mergeTarget.
+func mergeConfig(dst rawMap, src rawMap) {
+	for k, v := range src {
+		switch k {
+		case "variables", "golang":
+			dst[k] = mergeKeys(dst[k], v)
 
-	f, err := os.Open(filename)
+		case "targets":
+			targets := toRawMap(dst[k])
+			for name, target := range toRawMap(v) {
+				targets[name] = mergeTarget(toRawMap(targets[name]), toRawMap(target))
+			}
+			dst[k] = targets
+
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+// readFile reads a config file and the files it includes. Included files are
+// relative to the including file, and are merged in order, with the
+// including file merged on top of them.
+func readFile(filename string, seen []string) (rawMap, error) {
+	abs, err := filepath.Abs(filename)
 	if err != nil {
+		return nil, err
+	}
+	for _, s := range seen {
+		if s == abs {
+			return nil, fmt.Errorf("%s: circular include", filename)
+		}
+	}
+	seen = append(seen, abs)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// strict decoding of each file, to report errors with proper line numbers
+	conf := &Config{}
+	if err := yaml.UnmarshalStrict(data, conf); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	raw := rawMap{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	rv := rawMap{}
+	for _, include := range conf.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		inc, err := readFile(include, seen)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(rv, inc)
+	}
+
+	delete(raw, "include")
+	mergeConfig(rv, raw)
+
+	return rv, nil
+}
+
+// Read reads the config file, and merges `.yatr.local.yml` from the same
+// directory on top of it, if available. If required is not set, a missing
+// config file is handled as an empty config.
+func Read(filename string, required bool) (*Config, error) {
+	raw := rawMap{}
+
+	files := []string{filename}
+	if _, err := os.Stat(filename); os.IsNotExist(err) && !required {
 		// must work fine without config
-		return conf, nil
+		files = nil
+	}
+
+	local := filepath.Join(filepath.Dir(filename), ".yatr.local.yml")
+	if _, err := os.Stat(local); err == nil {
+		files = append(files, local)
+	}
+
+	for _, f := range files {
+		r, err := readFile(f, nil)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(raw, r)
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
 	}
-	defer f.Close()
 
-	if err := yaml.NewDecoder(f).Decode(conf); err != nil {
+	conf := &Config{}
+	if err := yaml.UnmarshalStrict(data, conf); err != nil {
 		return nil, err
 	}
 
+	conf.rawTargets = map[string]rawMap{}
+	for name, target := range toRawMap(raw["targets"]) {
+		conf.rawTargets[fmt.Sprint(name)] = toRawMap(target)
+	}
+
 	return conf, nil
 }
+
+func (c *Config) TargetNames() []string {
+	rv := []string{}
+	for name := range c.Targets {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	_, err := filepath.Match(pattern, "")
+	return err
+}
+
+// validate returns the problems found in the target, that don't depend on
+// the source directory. Values that are templates can only be checked after
+// rendering, so they are skipped.
+func (t *Target) validate() []string {
+	errs := []string{}
+
+	patterns := []keyValue{
+		{"archive_filter", t.ArchiveFilter},
+		{"archive_extract_filter", t.ArchiveExtractFilter},
+	}
+	for _, p := range patterns {
+		if isTemplate(p.value) {
+			continue
+		}
+		if err := validatePattern(p.value); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s %q: %s", p.key, p.value, err))
+		}
+	}
+
+	hooks := t.Hooks.all()
+	for _, stage := range hookStages(hooks) {
+		hook := hooks[stage]
+		if hook == nil {
+			continue
+		}
+
+		if hook.Script == "" {
+			errs = append(errs, fmt.Sprintf("missing hooks.%s.script", stage))
+		}
+
+		switch hook.OnFailure {
+		case "", "abort", "warn", "ignore":
+		default:
+			errs = append(errs, fmt.Sprintf("invalid hooks.%s.on_failure %q: must be abort, warn or ignore", stage, hook.OnFailure))
+		}
+	}
+
+	return errs
+}
+
+func hookStages(hooks map[string]*Hook) []string {
+	rv := []string{}
+	for stage := range hooks {
+		rv = append(rv, stage)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// Validate checks the target, including that its scripts exist, relative
+// to srcDir. It is called for each target before configure, and again
+// after some of its templates are rendered.
+func (t *Target) Validate(srcDir string) error {
+	errs := t.validate()
+
+	scripts := []keyValue{
+		{"task_script", t.TaskScript},
+	}
+	hooks := t.Hooks.all()
+	for _, stage := range hookStages(hooks) {
+		if hook := hooks[stage]; hook != nil {
+			scripts = append(scripts, keyValue{fmt.Sprintf("hooks.%s.script", stage), hook.Script})
+		}
+	}
+
+	for _, s := range scripts {
+		if s.value == "" || isTemplate(s.value) {
+			continue
+		}
+		script := s.value
+		if !filepath.IsAbs(script) {
+			script = filepath.Join(srcDir, script)
+		}
+		if st, err := os.Stat(script); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %s", s.key, err))
+		} else if st.IsDir() {
+			errs = append(errs, fmt.Sprintf("invalid %s: %s is a directory", s.key, script))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid target:\n    %s", strings.Join(errs, "\n    "))
+	}
+	return nil
+}
+
+// Validate checks the values that the decoder can't, for the whole config.
+// Problems that depend on the source directory, like missing scripts, are
+// only reported by Target.Validate, for the targets being executed. All the
+// errors found are reported at once.
+func (c *Config) Validate() error {
+	errs := []string{}
+
+	vars := []keyValue{
+		{"golang.version_var", c.Golang.VersionVar},
+		{"golang.commit_var", c.Golang.CommitVar},
+		{"golang.date_var", c.Golang.DateVar},
+		{"golang.target_var", c.Golang.TargetVar},
+	}
+	for _, v := range vars {
+		if v.value == "" {
+			continue
+		}
+		if !strings.Contains(v.value, ".") || strings.ContainsAny(v.value, " \t=") {
+			errs = append(errs, fmt.Sprintf("invalid %s %q: must be a full variable name, like main.version", v.key, v.value))
+		}
+	}
+
+	for _, name := range c.TargetNames() {
+		target, err := c.GetTarget(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, err := range target.validate() {
+			errs = append(errs, fmt.Sprintf("target %q: %s", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n    %s", strings.Join(errs, "\n    "))
+	}
+	return nil
+}
+
+// TargetVariables returns the global variables, overridden by the target
+// ones.
+func (c *Config) TargetVariables(target *Target) map[string]string {
+	rv := map[string]string{}
+	for k, v := range c.Variables {
+		rv[k] = v
+	}
+	for k, v := range target.Variables {
+		rv[k] = v
+	}
+	return rv
+}
+
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// lookupTarget returns the name of the declared target that matches
+// targetName. An exact match wins, otherwise the longest matching pattern
+// is used, e.g. `dist-linux-*` is preferred over `dist-*`.
+func (c *Config) lookupTarget(targetName string) (string, bool) {
+	if _, found := c.Targets[targetName]; found {
+		return targetName, true
+	}
+
+	rv := ""
+	for _, name := range c.TargetNames() {
+		if !isPattern(name) || len(name) <= len(rv) {
+			continue
+		}
+		if matched, err := filepath.Match(name, targetName); err == nil && matched {
+			rv = name
+		}
+	}
+	return rv, rv != ""
+}
+
+// HasTarget reports whether targetName is declared, directly or by a
+// pattern, or if the config does not declare any target at all.
+func (c *Config) HasTarget(targetName string) bool {
+	if len(c.Targets) == 0 {
+		return true
+	}
+	_, found := c.lookupTarget(targetName)
+	return found
+}
+
+// rawTargetsFromTargets returns the raw definitions of Targets, for configs
+// that were not read from files. Zero values are handled as unset, so they
+// don't override the values of the extended targets.
+func (c *Config) rawTargetsFromTargets() (map[string]rawMap, error) {
+	rv := map[string]rawMap{}
+	for name, target := range c.Targets {
+		data, err := yaml.Marshal(target)
+		if err != nil {
+			return nil, err
+		}
+		raw := rawMap{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		rv[name] = raw
+	}
+	return rv, nil
+}
+
+func resolveRawTarget(rawTargets map[string]rawMap, name string, seen []string) (rawMap, error) {
+	for _, s := range seen {
+		if s == name {
+			return nil, fmt.Errorf("target %q: circular extends: %s -> %s", seen[0], strings.Join(seen, " -> "), name)
+		}
+	}
+	seen = append(seen, name)
+
+	raw, found := rawTargets[name]
+	if !found {
+		if len(seen) > 1 {
+			return nil, fmt.Errorf("target %q: extends undeclared target %q", seen[len(seen)-2], name)
+		}
+		return nil, fmt.Errorf("target %q: not declared", name)
+	}
+
+	base := rawMap{}
+	if extends, ok := raw["extends"]; ok && extends != nil {
+		var err error
+		base, err = resolveRawTarget(rawTargets, fmt.Sprint(extends), seen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rv := mergeTarget(base, raw)
+	delete(rv, "extends")
+
+	return rv, nil
+}
+
+// GetTarget returns the definition of targetName, resolving patterns and
+// inheritance. A target that extends another one starts with all the keys
+// of its base target, merged with mergeTarget. Undeclared targets return an
+// empty definition.
+func (c *Config) GetTarget(targetName string) (*Target, error) {
+	name, found := c.lookupTarget(targetName)
+	if !found {
+		return &Target{}, nil
+	}
+
+	rawTargets := c.rawTargets
+	if rawTargets == nil {
+		var err error
+		rawTargets, err = c.rawTargetsFromTargets()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := resolveRawTarget(rawTargets, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &Target{}
+	if err := yaml.UnmarshalStrict(data, rv); err != nil {
+		return nil, fmt.Errorf("target %q: %s", targetName, err)
+	}
+	return rv, nil
+}
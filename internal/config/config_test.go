@@ -0,0 +1,487 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// readConfig writes files to a temporary directory, and reads `.yatr.yml`
+// from it.
+func readConfig(t *testing.T, files map[string]string) (*Config, error) {
+	dir, err := ioutil.TempDir("", "yatr-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Read(filepath.Join(dir, ".yatr.yml"), true)
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		target   string
+		expected *Target
+	}{
+		{
+			name: "extends replaces lists",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  base:
+    task_args: [a, b]
+    configure_args: [c]
+  child:
+    extends: base
+    task_args: [d]
+`,
+			},
+			target: "child",
+			expected: &Target{
+				TaskArgs:      []string{"d"},
+				ConfigureArgs: []string{"c"},
+			},
+		},
+		{
+			name: "local config replaces lists",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  dist:
+    task_args: [a, b]
+    task_script: build.sh
+`,
+				".yatr.local.yml": `
+targets:
+  dist:
+    task_args: [c]
+`,
+			},
+			target: "dist",
+			expected: &Target{
+				TaskArgs:   []string{"c"},
+				TaskScript: "build.sh",
+			},
+		},
+		{
+			name: "extends merges variables",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  base:
+    variables: {a: "1", b: "2"}
+  child:
+    extends: base
+    variables: {b: "3", c: "4"}
+`,
+			},
+			target: "child",
+			expected: &Target{
+				Variables: map[string]string{"a": "1", "b": "3", "c": "4"},
+			},
+		},
+		{
+			name: "include merges variables",
+			files: map[string]string{
+				"base.yml": `
+targets:
+  dist:
+    variables: {a: "1", b: "2"}
+    task_args: [a]
+`,
+				".yatr.yml": `
+include: [base.yml]
+targets:
+  dist:
+    variables: {b: "3"}
+`,
+			},
+			target: "dist",
+			expected: &Target{
+				Variables: map[string]string{"a": "1", "b": "3"},
+				TaskArgs:  []string{"a"},
+			},
+		},
+		{
+			name: "extends merges hooks by stage",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  base:
+    hooks:
+      pre_task: {script: pre.sh, args: [a]}
+      post_task: {script: post.sh, on_failure: warn}
+  child:
+    extends: base
+    hooks:
+      post_task: {script: other.sh}
+`,
+			},
+			target: "child",
+			expected: &Target{
+				Hooks: Hooks{
+					PreTask:  &Hook{Script: "pre.sh", Args: []string{"a"}},
+					PostTask: &Hook{Script: "other.sh"},
+				},
+			},
+		},
+		{
+			name: "local config merges hooks by stage",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  dist:
+    hooks:
+      pre_task: {script: pre.sh}
+`,
+				".yatr.local.yml": `
+targets:
+  dist:
+    hooks:
+      post_publish: {script: notify.sh}
+`,
+			},
+			target: "dist",
+			expected: &Target{
+				Hooks: Hooks{
+					PreTask:     &Hook{Script: "pre.sh"},
+					PostPublish: &Hook{Script: "notify.sh"},
+				},
+			},
+		},
+		{
+			name: "multi-level extends",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  a:
+    runner: golang
+    task_args: [a]
+    variables: {a: "a"}
+  b:
+    extends: a
+    task_args: [b]
+    variables: {b: "b"}
+  c:
+    extends: b
+    variables: {a: "c"}
+`,
+			},
+			target: "c",
+			expected: &Target{
+				Runner:    "golang",
+				TaskArgs:  []string{"b"},
+				Variables: map[string]string{"a": "c", "b": "b"},
+			},
+		},
+		{
+			name: "exact match wins over pattern",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  dist-linux-*:
+    task_args: [pattern]
+  dist-linux-amd64:
+    task_args: [exact]
+`,
+			},
+			target: "dist-linux-amd64",
+			expected: &Target{
+				TaskArgs: []string{"exact"},
+			},
+		},
+		{
+			name: "longest pattern wins",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  dist-*:
+    task_args: [short]
+  dist-linux-*:
+    task_args: [long]
+  "*":
+    task_args: [any]
+`,
+			},
+			target: "dist-linux-arm64",
+			expected: &Target{
+				TaskArgs: []string{"long"},
+			},
+		},
+		{
+			name: "undeclared target",
+			files: map[string]string{
+				".yatr.yml": `
+targets:
+  dist-*:
+    task_args: [a]
+`,
+			},
+			target:   "distcheck",
+			expected: &Target{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := readConfig(t, test.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, err := conf.GetTarget(test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target, test.expected) {
+				t.Errorf("got %+v, expected %+v", target, test.expected)
+			}
+		})
+	}
+}
+
+func TestMergeGolang(t *testing.T) {
+	conf, err := readConfig(t, map[string]string{
+		"base.yml": `
+golang:
+  commit_var: main.commit
+  platforms: [linux-amd64, windows-amd64]
+`,
+		".yatr.yml": `
+include: [base.yml]
+golang:
+  version_var: main.version
+  platforms: [linux-arm64]
+`,
+		".yatr.local.yml": `
+golang:
+  reproducible: true
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.Golang{
+		VersionVar:   "main.version",
+		CommitVar:    "main.commit",
+		Reproducible: true,
+		Platforms:    []string{"linux-arm64"},
+	}
+	if !reflect.DeepEqual(conf.Golang, expected) {
+		t.Errorf("got %+v, expected %+v", conf.Golang, expected)
+	}
+}
+
+func TestExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		target string
+		err    string
+	}{
+		{
+			name: "circular",
+			config: `
+targets:
+  a:
+    extends: b
+  b:
+    extends: c
+  c:
+    extends: a
+`,
+			target: "a",
+			err:    `target "a": circular extends: a -> b -> c -> a`,
+		},
+		{
+			name: "self",
+			config: `
+targets:
+  a:
+    extends: a
+`,
+			target: "a",
+			err:    `target "a": circular extends: a -> a`,
+		},
+		{
+			name: "undeclared",
+			config: `
+targets:
+  a:
+    extends: b
+  b:
+    extends: c
+`,
+			target: "a",
+			err:    `target "b": extends undeclared target "c"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := readConfig(t, map[string]string{".yatr.yml": test.config})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conf.GetTarget(test.target); err == nil || err.Error() != test.err {
+				t.Errorf("got error %v, expected %q", err, test.err)
+			}
+
+			// also reported by validation
+			if err := conf.Validate(); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got validation error %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "top level",
+			files: map[string]string{".yatr.yml": "runnr: make\n"},
+			err:   "line 1: field runnr not found",
+		},
+		{
+			name: "target",
+			files: map[string]string{".yatr.yml": `
+targets:
+  dist:
+    task_scirpt: foo.sh
+`},
+			err: "line 4: field task_scirpt not found",
+		},
+		{
+			name: "hook",
+			files: map[string]string{".yatr.yml": `
+targets:
+  dist:
+    hooks:
+      pre_task:
+        script: foo.sh
+        on_fail: warn
+`},
+			err: "line 7: field on_fail not found",
+		},
+		{
+			name: "golang",
+			files: map[string]string{".yatr.yml": `
+golang:
+  reproducable: true
+`},
+			err: "line 3: field reproducable not found",
+		},
+		{
+			name: "include",
+			files: map[string]string{
+				".yatr.yml": "include:\n  - base.yml\n",
+				"base.yml":  "variables:\n  foo: bar\nvariable:\n  foo: bar\n",
+			},
+			err: "base.yml: yaml: unmarshal errors:\n  line 3: field variable not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readConfig(t, test.files); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yatr-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "build.sh"), []byte("#!/bin/sh\n"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := readConfig(t, map[string]string{".yatr.yml": `
+targets:
+  ok:
+    task_script: build.sh
+    archive_filter: "*.tar.gz"
+  template:
+    task_script: "{{ .Target }}.sh"
+    archive_filter: "{{ .Target }}-["
+  missing-script:
+    task_script: missing.sh
+  bad-glob:
+    archive_filter: "foo-["
+  bad-hook:
+    hooks:
+      pre_task:
+        on_failure: retry
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// missing scripts don't make the whole config invalid
+	err = conf.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	expected := []string{
+		`target "bad-glob": invalid archive_filter "foo-[": syntax error in pattern`,
+		`target "bad-hook": missing hooks.pre_task.script`,
+		`target "bad-hook": invalid hooks.pre_task.on_failure "retry": must be abort, warn or ignore`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("error %q not found in %q", e, err)
+		}
+	}
+	if strings.Contains(err.Error(), "missing.sh") {
+		t.Errorf("unexpected missing script error: %q", err)
+	}
+
+	tests := []struct {
+		target string
+		err    string
+	}{
+		{"ok", ""},
+		{"template", ""},
+		{"missing-script", "invalid task_script: stat " + filepath.Join(dir, "missing.sh") + ": no such file or directory"},
+		{"bad-glob", `invalid archive_filter "foo-[": syntax error in pattern`},
+	}
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			target, err := conf.GetTarget(test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = target.Validate(dir)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
@@ -1,18 +1,48 @@
 package executils
 
 import (
+	"errors"
 	"log"
 	"os"
 	"os/exec"
 	"syscall"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
 )
 
+// ExitCode returns the exit status of the command that caused err, 0 if err
+// is nil and -1 if err was not caused by a command exit status.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return ws.ExitStatus()
+		}
+	}
+	return -1
+}
+
 func Run(cmd *exec.Cmd) error {
 	log.Printf("    Running command: %q", cmd.Args)
 	if cmd.Dir != "" {
 		log.Println("          Directory:", cmd.Dir)
 	}
 
+	if plan.Enabled() {
+		plan.Record(&plan.Operation{
+			Type: "exec",
+			Args: cmd.Args,
+			Dir:  cmd.Dir,
+			Env:  plan.EnvDiff(cmd.Env),
+		})
+		log.Println("          (not executed, dry-run)")
+		return nil
+	}
+
 	if cmd.Stdout == nil {
 		cmd.Stdout = os.Stdout
 	}
@@ -22,13 +52,9 @@ func Run(cmd *exec.Cmd) error {
 
 	err := cmd.Run()
 
-	code := 0
-	if err != nil {
-		if msg, ok := err.(*exec.ExitError); ok {
-			if ws, ok := msg.Sys().(syscall.WaitStatus); ok {
-				code = ws.ExitStatus()
-			}
-		}
+	code := ExitCode(err)
+	if code < 0 {
+		code = 0
 	}
 	log.Println("          Exit code:", code)
 	return err
@@ -2,8 +2,12 @@ package fs
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
 )
 
 var licenseFiles = []string{
@@ -28,6 +32,17 @@ func FindLicense(dir string) string {
 	return ""
 }
 
+// DetectFile looks for the first of names that exists in dir, and describes
+// the result.
+func DetectFile(dir string, names ...string) (bool, string) {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true, name + " found"
+		}
+	}
+	return false, strings.Join(names, ", ") + " not found"
+}
+
 func FindReadme(dir string) string {
 	for _, entry := range readmeFiles {
 		bentry := filepath.Join("build-aux", entry)
@@ -82,3 +97,59 @@ func FilterArchives(archives []string, pattern string) []string {
 	}
 	return rv
 }
+
+// CollectArchives places the regular files found in dir, with one of exts
+// and accepted by accept, if not nil, in the root of buildDir, where
+// publishers expect archives. Files are moved, or copied if keep is set.
+// Nothing is placed on dry-run, but the names are still returned.
+func CollectArchives(dir string, buildDir string, exts []string, accept func(fileInfo os.FileInfo) bool, keep bool) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []string
+	for _, fileInfo := range files {
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+
+		found := false
+		for _, ext := range exts {
+			if strings.HasSuffix(fileInfo.Name(), "."+ext) {
+				found = true
+				break
+			}
+		}
+		if !found || (accept != nil && !accept(fileInfo)) {
+			continue
+		}
+
+		if !plan.Enabled() {
+			src := filepath.Join(dir, fileInfo.Name())
+			dst := filepath.Join(buildDir, fileInfo.Name())
+			if keep {
+				err = CopyFile(src, dst)
+			} else {
+				err = MoveFile(src, dst)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		rv = append(rv, fileInfo.Name())
+	}
+	return rv, nil
+}
+
+func MoveFile(srcName string, dstName string) error {
+	if err := os.Rename(srcName, dstName); err == nil {
+		return nil
+	}
+
+	// rename fails across filesystems
+	if err := CopyFile(srcName, dstName); err != nil {
+		return err
+	}
+	return os.Remove(srcName)
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rafaelmartins/yatr/internal/executils"
 )
@@ -48,3 +50,76 @@ func Unshallow(repoDir string) error {
 	cmd.Dir = repoDir
 	return executils.Run(cmd)
 }
+
+// TrackedFiles returns the files tracked in repoDir, relative to it. It is
+// empty if repoDir is not a git repository.
+func TrackedFiles(repoDir string) map[string]bool {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = repoDir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return map[string]bool{}
+	}
+
+	rv := map[string]bool{}
+	for _, f := range strings.Split(out.String(), "\x00") {
+		if f != "" {
+			rv[f] = true
+		}
+	}
+	return rv
+}
+
+func revParse(repoDir string, args ...string) string {
+	var out bytes.Buffer
+	cmd := exec.Command("git", append([]string{"rev-parse"}, args...)...)
+	cmd.Dir = repoDir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func Commit(repoDir string) string {
+	return revParse(repoDir, "HEAD")
+}
+
+func ShortCommit(repoDir string) string {
+	return revParse(repoDir, "--short", "HEAD")
+}
+
+func Branch(repoDir string) string {
+	if branch := revParse(repoDir, "--abbrev-ref", "HEAD"); branch != "HEAD" {
+		return branch
+	}
+	return "" // detached HEAD
+}
+
+func Tag(repoDir string) string {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "describe", "--tags", "--exact-match", "HEAD")
+	cmd.Dir = repoDir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// CommitTime returns the committer date of HEAD.
+func CommitTime(repoDir string) (time.Time, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", "HEAD")
+	cmd.Dir = repoDir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return time.Time{}, err
+	}
+	ts, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0).UTC(), nil
+}
@@ -0,0 +1,137 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Operation struct {
+	Target string            `json:"target,omitempty"`
+	Type   string            `json:"type"`
+	Args   []string          `json:"args,omitempty"`
+	Dir    string            `json:"dir,omitempty"`
+	Env    []string          `json:"env,omitempty"`
+	Method string            `json:"method,omitempty"`
+	URL    string            `json:"url,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Files  []string          `json:"files,omitempty"`
+}
+
+var (
+	enabled    bool
+	mtx        sync.Mutex
+	target     string
+	operations []*Operation
+)
+
+func Enable() {
+	enabled = true
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+// Reset disables dry-run and drops the recorded operations. It is only
+// meant for tests, dry-run can't be disabled otherwise.
+func Reset() {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	enabled = false
+	target = ""
+	operations = nil
+}
+
+func SetTarget(targetName string) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	target = targetName
+}
+
+func Record(op *Operation) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	op.Target = target
+	operations = append(operations, op)
+}
+
+func Operations() []*Operation {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	return append([]*Operation{}, operations...)
+}
+
+// EnvDiff returns the entries of env that are not inherited from the
+// current process environment, to keep the plan readable.
+func EnvDiff(env []string) []string {
+	inherited := map[string]bool{}
+	for _, e := range os.Environ() {
+		inherited[e] = true
+	}
+
+	rv := []string{}
+	for _, e := range env {
+		if !inherited[e] {
+			rv = append(rv, e)
+		}
+	}
+	return rv
+}
+
+func WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(map[string]interface{}{
+		"operations": Operations(),
+	})
+}
+
+func WriteText(w io.Writer) error {
+	lastTarget := ""
+	for i, op := range Operations() {
+		if i == 0 || op.Target != lastTarget {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			if op.Target != "" {
+				fmt.Fprintf(w, "# target: %s\n", op.Target)
+			}
+			lastTarget = op.Target
+		}
+
+		if op.Type == "http" {
+			fmt.Fprintf(w, "http: %s %s\n", op.Method, op.URL)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", op.Type, strings.Join(op.Args, " "))
+		}
+
+		if op.Dir != "" {
+			fmt.Fprintf(w, "    dir: %s\n", op.Dir)
+		}
+		for _, e := range op.Env {
+			fmt.Fprintf(w, "    env: %s\n", e)
+		}
+		for _, f := range op.Files {
+			fmt.Fprintf(w, "    file: %s\n", f)
+		}
+
+		keys := []string{}
+		for k := range op.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "    field: %s=%s\n", k, op.Fields[k])
+		}
+	}
+	return nil
+}
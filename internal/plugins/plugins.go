@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/plan"
+)
+
+// Plugin is an executable named `<prefix><name>` that implements a method
+// per call: it receives the method name as its only argument, a JSON
+// request in stdin and must write a JSON response to stdout. Anything
+// written to stderr is passed through. A response may include an `error`
+// string, and a non-zero exit status is always an error.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+type response struct {
+	Error string `json:"error"`
+}
+
+// Find returns the plugins found in dirs and then in PATH. If several
+// plugins have the same name, the first one found is used.
+func Find(prefix string, dirs []string) []*Plugin {
+	rv := []*Plugin{}
+	found := map[string]bool{}
+
+	for _, dir := range append(dirs, filepath.SplitList(os.Getenv("PATH"))...) {
+		if dir == "" {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		names := []string{}
+		for _, fileInfo := range files {
+			if !strings.HasPrefix(fileInfo.Name(), prefix) {
+				continue
+			}
+			if st, err := os.Stat(filepath.Join(dir, fileInfo.Name())); err != nil || !st.Mode().IsRegular() || st.Mode()&0111 == 0 {
+				continue
+			}
+			names = append(names, fileInfo.Name())
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			pluginName := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".exe")
+			if pluginName == "" || found[pluginName] {
+				continue
+			}
+			found[pluginName] = true
+
+			rv = append(rv, &Plugin{
+				Name: pluginName,
+				Path: filepath.Join(dir, name),
+			})
+		}
+	}
+
+	return rv
+}
+
+// Call runs a plugin method. If verbose is set, the call is logged like
+// any other command.
+func (p *Plugin) Call(method string, req interface{}, resp interface{}, verbose bool) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(p.Path, method)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if verbose {
+		log.Printf("    Running plugin: %q", cmd.Args)
+		if plan.Enabled() {
+			plan.Record(&plan.Operation{
+				Type: "plugin",
+				Args: cmd.Args,
+			})
+		}
+	}
+	err = cmd.Run()
+	if verbose {
+		code := executils.ExitCode(err)
+		if code < 0 {
+			code = 0
+		}
+		log.Println("          Exit code:", code)
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s failed: %s", p.Name, method, err)
+	}
+
+	r := response{}
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		return fmt.Errorf("plugin %s: %s: invalid response: %s", p.Name, method, err)
+	}
+	if r.Error != "" {
+		return fmt.Errorf("plugin %s: %s: %s", p.Name, method, r.Error)
+	}
+
+	if resp != nil {
+		if err := json.Unmarshal(out.Bytes(), resp); err != nil {
+			return fmt.Errorf("plugin %s: %s: invalid response: %s", p.Name, method, err)
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/rafaelmartins/yatr/internal/plan"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
@@ -26,23 +27,68 @@ func (p *DistfilesApiPublisher) Name() string {
 }
 
 func (p *DistfilesApiPublisher) Detect(ctx *types.Ctx) bool {
+	detected, _ := p.Explain(ctx)
+	return detected
+}
+
+func (p *DistfilesApiPublisher) Explain(ctx *types.Ctx) (bool, string) {
 	if url := strings.TrimSpace(os.Getenv("DISTFILES_URL")); url != "" {
 		p.url = url
-		return true
+		return true, "DISTFILES_URL is set"
 	}
-	return false
+	return false, "DISTFILES_URL is not set"
 }
 
 func (p *DistfilesApiPublisher) SetRelease(release bool) {
 	p.release = release
 }
 
+func (p *DistfilesApiPublisher) params(proj *types.Project, archive string, pattern string) map[string]string {
+	releaseStr := "false"
+	if p.release {
+		releaseStr = "true"
+	}
+
+	extract := false
+	if len(pattern) > 0 {
+		var err error
+		extract, err = filepath.Match(pattern, archive)
+		if err != nil {
+			extract = false
+		}
+	}
+
+	extractStr := "false"
+	if extract {
+		extractStr = "true"
+	}
+
+	return map[string]string{
+		"project": proj.Name,
+		"version": proj.Version,
+		"release": releaseStr,
+		"extract": extractStr,
+	}
+}
+
 func (p *DistfilesApiPublisher) Publish(ctx *types.Ctx, proj *types.Project, archives []string, pattern string) error {
 	for _, archive := range archives {
 		log.Println("    - Uploading archive:", archive)
 
 		fn := filepath.Join(ctx.BuildDir, archive)
 
+		if plan.Enabled() {
+			plan.Record(&plan.Operation{
+				Type:   "http",
+				Method: http.MethodPost,
+				URL:    p.url,
+				Fields: p.params(proj, archive, pattern),
+				Files:  []string{fn},
+			})
+			log.Println("          (not uploaded, dry-run)")
+			continue
+		}
+
 		body := new(bytes.Buffer)
 		writer := multipart.NewWriter(body)
 		part, err := writer.CreateFormFile("file", archive)
@@ -65,32 +111,8 @@ func (p *DistfilesApiPublisher) Publish(ctx *types.Ctx, proj *types.Project, arc
 			return err
 		}
 
-		releaseStr := "false"
-		if p.release {
-			releaseStr = "true"
-		}
-
-		extract := false
-		if len(pattern) > 0 {
-			var err error
-			extract, err = filepath.Match(pattern, archive)
-			if err != nil {
-				extract = false
-			}
-		}
-
-		extractStr := "false"
-		if extract {
-			extractStr = "true"
-		}
-
-		reqParams := map[string]string{
-			"project": proj.Name,
-			"version": proj.Version,
-			"sha512":  fmt.Sprintf("%x  %s", checksum.Sum(nil), archive),
-			"release": releaseStr,
-			"extract": extractStr,
-		}
+		reqParams := p.params(proj, archive, pattern)
+		reqParams["sha512"] = fmt.Sprintf("%x  %s", checksum.Sum(nil), archive)
 
 		for key, value := range reqParams {
 			if err := writer.WriteField(key, value); err != nil {
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/plugins"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// PluginPublisher implements a publisher with an external `yatr-publisher-*`
+// executable. Its methods are `detect` and `publish`, and both receive a
+// request like:
+//
+//	{"ctx": {...}, "project": {...}, "archives": [...], "release": false,
+//	 "extract_pattern": "...", "dry_run": false}
+//
+// Only `ctx` and `dry_run` are sent to `detect`. Responses are
+// `{"detected": true, "evidence": "..."}` for `detect`, where evidence is
+// optional, and, for `publish`:
+//
+//	{"results": [{"archive": "...", "url": "...", "error": "..."}]}
+//
+// with archives relative to the build directory. On dry-run, plugins must
+// not upload anything.
+type PluginPublisher struct {
+	Plugin  *plugins.Plugin
+	release bool
+}
+
+type request struct {
+	Ctx            *types.Ctx     `json:"ctx"`
+	Project        *types.Project `json:"project,omitempty"`
+	Archives       []string       `json:"archives,omitempty"`
+	Release        bool           `json:"release"`
+	ExtractPattern string         `json:"extract_pattern,omitempty"`
+	DryRun         bool           `json:"dry_run"`
+}
+
+type result struct {
+	Archive string `json:"archive"`
+	URL     string `json:"url"`
+	Error   string `json:"error"`
+}
+
+func (p *PluginPublisher) Name() string {
+	return p.Plugin.Name
+}
+
+func (p *PluginPublisher) Detect(ctx *types.Ctx) bool {
+	detected, _ := p.Explain(ctx)
+	return detected
+}
+
+func (p *PluginPublisher) Explain(ctx *types.Ctx) (bool, string) {
+	resp := struct {
+		Detected bool   `json:"detected"`
+		Evidence string `json:"evidence"`
+	}{}
+	if err := p.Plugin.Call("detect", &request{Ctx: ctx, DryRun: plan.Enabled()}, &resp, false); err != nil {
+		log.Println("Warning: ", err)
+		return false, err.Error()
+	}
+	if resp.Evidence == "" {
+		resp.Evidence = "plugin " + p.Plugin.Path
+	}
+	return resp.Detected, resp.Evidence
+}
+
+func (p *PluginPublisher) SetRelease(release bool) {
+	p.release = release
+}
+
+func (p *PluginPublisher) Publish(ctx *types.Ctx, proj *types.Project, archives []string, pattern string) error {
+	req := &request{
+		Ctx:            ctx,
+		Project:        proj,
+		Archives:       archives,
+		Release:        p.release,
+		ExtractPattern: pattern,
+		DryRun:         plan.Enabled(),
+	}
+	resp := struct {
+		Results []*result `json:"results"`
+	}{}
+	if err := p.Plugin.Call("publish", req, &resp, true); err != nil {
+		return err
+	}
+
+	published := map[string]bool{}
+	for _, r := range resp.Results {
+		log.Println("    - Archive:", r.Archive)
+		if r.Error != "" {
+			log.Println("          Failed:", r.Error)
+			continue
+		}
+		published[r.Archive] = true
+		if r.URL != "" {
+			log.Println("          URL:", r.URL)
+		}
+		log.Println("          Done!")
+	}
+
+	// archives missing from the results are failures as well
+	failed := 0
+	for _, archive := range archives {
+		if !published[archive] {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("plugin %s: failed to publish %d archive(s)", p.Plugin.Name, failed)
+	}
+	return nil
+}
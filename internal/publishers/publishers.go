@@ -3,9 +3,12 @@ package publishers
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/rafaelmartins/yatr/internal/plugins"
 	"github.com/rafaelmartins/yatr/internal/publishers/distfiles_api"
+	"github.com/rafaelmartins/yatr/internal/publishers/plugin"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
@@ -16,52 +19,118 @@ type Publisher interface {
 	Publish(ctx *types.Ctx, proj *types.Project, archives []string, pattern string) error
 }
 
-var publishers = []Publisher{
-	&distfiles_api.DistfilesApiPublisher{},
+var publishers = []func() Publisher{
+	func() Publisher { return &distfiles_api.DistfilesApiPublisher{} },
 }
 
-func Get(ctx *types.Ctx) (Publisher, error) {
+var registered = []func() Publisher{}
+
+// Register adds a publisher, that has precedence over the built-in
+// publishers. It must not be called while a pipeline is running.
+func Register(f func() Publisher) {
+	registered = append(registered, f)
+}
+
+// getPublishers returns the publisher plugins found for srcDir, followed by
+// the registered publishers and the built-in publishers.
+func getPublishers(srcDir string) []func() Publisher {
+	rv := []func() Publisher{}
+	for _, p := range plugins.Find("yatr-publisher-", []string{filepath.Join(srcDir, ".yatr", "publishers")}) {
+		p := p
+		rv = append(rv, func() Publisher { return &plugin.PluginPublisher{Plugin: p} })
+	}
+	rv = append(rv, registered...)
+	return append(rv, publishers...)
+}
+
+// Explainer is implemented by publishers that can tell why they are
+// available, or why not.
+type Explainer interface {
+	Explain(ctx *types.Ctx) (bool, string)
+}
+
+// CheckEnv checks if publishing is allowed by the environment, and if it is
+// a release. The returned evidence lists the checks that were performed.
+func CheckEnv() (release bool, evidence []string, err error) {
 
 	// publisher disabled by the user
 	if v := strings.ToLower(os.Getenv("DISABLE_PUBLISHER")); v == "1" || v == "true" || v == "on" {
-		return nil, fmt.Errorf("disabled by DISABLE_PUBLISHER")
+		return false, []string{fmt.Sprintf("DISABLE_PUBLISHER=%s", os.Getenv("DISABLE_PUBLISHER"))}, fmt.Errorf("disabled by DISABLE_PUBLISHER")
 	}
 
 	// environment checks:
 	// - don't run on pull requests
 	// - only run for master and tags
 
-	isRelease := false
-
 	// travis
 	if os.Getenv("TRAVIS") == "true" {
+		evidence = append(evidence,
+			"travis: TRAVIS=true",
+			fmt.Sprintf("travis: TRAVIS_PULL_REQUEST=%s", os.Getenv("TRAVIS_PULL_REQUEST")),
+			fmt.Sprintf("travis: TRAVIS_BRANCH=%s", os.Getenv("TRAVIS_BRANCH")),
+			fmt.Sprintf("travis: TRAVIS_TAG=%s", os.Getenv("TRAVIS_TAG")),
+		)
 		if os.Getenv("TRAVIS_PULL_REQUEST") != "false" {
-			return nil, fmt.Errorf("disabled, pull request")
+			return false, evidence, fmt.Errorf("disabled, pull request")
 		}
 		if os.Getenv("TRAVIS_BRANCH") != "master" && os.Getenv("TRAVIS_TAG") == "" {
-			return nil, fmt.Errorf("disabled, not master branch nor a git tag")
+			return false, evidence, fmt.Errorf("disabled, not master branch nor a git tag")
 		}
 		if os.Getenv("TRAVIS_TAG") != "" {
-			isRelease = true
+			release = true
 		}
 	}
 
 	// github actions
 	if event, found := os.LookupEnv("GITHUB_EVENT_NAME"); found {
+		evidence = append(evidence,
+			fmt.Sprintf("github actions: GITHUB_EVENT_NAME=%s", event),
+			fmt.Sprintf("github actions: GITHUB_REF=%s", os.Getenv("GITHUB_REF")),
+		)
 		if event == "push" {
 			if ref := os.Getenv("GITHUB_REF"); ref != "refs/heads/master" && !strings.HasPrefix(ref, "refs/tags/") {
-				return nil, fmt.Errorf("disabled, not master branch nor a git tag")
+				return false, evidence, fmt.Errorf("disabled, not master branch nor a git tag")
 			}
 		} else {
-			return nil, fmt.Errorf("disabled, not push nor create event")
+			return false, evidence, fmt.Errorf("disabled, not push nor create event")
 		}
 		if strings.HasPrefix(os.Getenv("GITHUB_REF"), "refs/tags/") {
-			isRelease = true
+			release = true
+		}
+	}
+
+	if len(evidence) == 0 {
+		evidence = append(evidence, "no CI environment detected")
+	}
+
+	return release, evidence, nil
+}
+
+// Detect evaluates every publisher available for srcDir, in order of
+// precedence, without checking the environment.
+func Detect(ctx *types.Ctx) []*types.Detection {
+	rv := []*types.Detection{}
+	for _, f := range getPublishers(ctx.SrcDir) {
+		v := f()
+		d := &types.Detection{Name: v.Name()}
+		if e, ok := v.(Explainer); ok {
+			d.Detected, d.Evidence = e.Explain(ctx)
+		} else {
+			d.Detected = v.Detect(ctx)
 		}
+		rv = append(rv, d)
+	}
+	return rv
+}
+
+func Get(ctx *types.Ctx) (Publisher, error) {
+	isRelease, _, err := CheckEnv()
+	if err != nil {
+		return nil, err
 	}
 
-	for _, v := range publishers {
-		if v.Detect(ctx) {
+	for _, f := range getPublishers(ctx.SrcDir) {
+		if v := f(); v.Detect(ctx) {
 			v.SetRelease(isRelease)
 			return v, nil
 		}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+)
+
+type Step struct {
+	Duration float64 `json:"duration"`
+	ExitCode int     `json:"exit_code"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Archive struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA512 string `json:"sha512"`
+}
+
+type Target struct {
+	Target             string     `json:"target"`
+	Runner             string     `json:"runner,omitempty"`
+	Publisher          string     `json:"publisher,omitempty"`
+	ProjectName        string     `json:"project_name,omitempty"`
+	ProjectVersion     string     `json:"project_version,omitempty"`
+	Configure          *Step      `json:"configure,omitempty"`
+	Task               *Step      `json:"task,omitempty"`
+	Collect            *Step      `json:"collect,omitempty"`
+	Publish            *Step      `json:"publish,omitempty"`
+	Archives           []*Archive `json:"archives"`
+	TaskFailed         bool       `json:"task_failed"`
+	PublishedOnFailure bool       `json:"published_on_failure"`
+	Duration           float64    `json:"duration"`
+	Success            bool       `json:"success"`
+	Error              string     `json:"error,omitempty"`
+}
+
+type Report struct {
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	Targets   []*Target `json:"targets"`
+}
+
+// NewStep builds a step entry for a step started at start, that finished
+// with err. Exit code is -1 for errors not caused by a command exit status.
+func NewStep(start time.Time, err error) *Step {
+	rv := &Step{
+		Duration: time.Since(start).Seconds(),
+		ExitCode: executils.ExitCode(err),
+	}
+	if err != nil {
+		rv.Error = err.Error()
+	}
+	return rv
+}
+
+func NewArchive(dir string, name string) (*Archive, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	checksum := sha512.New()
+	size, err := io.Copy(checksum, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		Name:   name,
+		Size:   size,
+		SHA512: fmt.Sprintf("%x", checksum.Sum(nil)),
+	}, nil
+}
+
+func (r *Report) Write(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "    ")
+	return enc.Encode(r)
+}
@@ -13,6 +13,8 @@ import (
 	"strings"
 
 	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/plan"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
@@ -59,10 +61,17 @@ func (r *AutotoolsRunner) Name() string {
 	return "autotools"
 }
 
+func (r *AutotoolsRunner) ParallelSafe() bool {
+	return true
+}
+
 func (r *AutotoolsRunner) Detect(ctx *types.Ctx) bool {
-	path := path.Join(ctx.SrcDir, "configure.ac")
-	_, err := os.Stat(path)
-	return err == nil
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *AutotoolsRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "configure.ac")
 }
 
 func (r *AutotoolsRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
@@ -75,21 +84,24 @@ func (r *AutotoolsRunner) Configure(ctx *types.Ctx, args []string) (*types.Proje
 
 	configure := path.Join(ctx.SrcDir, "configure")
 
-	st, err := os.Stat(configure)
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("Error: `configure` script was not created")
-	}
-	if err != nil {
-		return nil, err
-	}
+	// autoreconf was not executed on dry-run
+	if !plan.Enabled() {
+		st, err := os.Stat(configure)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Error: `configure` script was not created")
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	if st.Mode()&0111 == 0 {
-		return nil, fmt.Errorf("Error: `configure` script is not executable")
+		if st.Mode()&0111 == 0 {
+			return nil, fmt.Errorf("Error: `configure` script is not executable")
+		}
 	}
 
 	cmd = exec.Command(configure, args...)
 	cmd.Dir = ctx.BuildDir
-	err = executils.Run(cmd)
+	err := executils.Run(cmd)
 
 	return getAutotoolsProject(ctx), err
 }
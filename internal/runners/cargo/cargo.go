@@ -0,0 +1,273 @@
+package cargo
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/compress"
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+var distTriples = map[string]string{
+	"darwin-amd64":  "x86_64-apple-darwin",
+	"darwin-arm64":  "aarch64-apple-darwin",
+	"freebsd-amd64": "x86_64-unknown-freebsd",
+	"linux-386":     "i686-unknown-linux-gnu",
+	"linux-amd64":   "x86_64-unknown-linux-gnu",
+	"linux-arm64":   "aarch64-unknown-linux-gnu",
+	"linux-armv6":   "arm-unknown-linux-gnueabihf",
+	"linux-armv7":   "armv7-unknown-linux-gnueabihf",
+	"linux-ppc64le": "powerpc64le-unknown-linux-gnu",
+	"linux-riscv64": "riscv64gc-unknown-linux-gnu",
+	"linux-s390x":   "s390x-unknown-linux-gnu",
+	"netbsd-amd64":  "x86_64-unknown-netbsd",
+	"windows-386":   "i686-pc-windows-msvc",
+	"windows-amd64": "x86_64-pc-windows-msvc",
+	"windows-arm64": "aarch64-pc-windows-msvc",
+}
+
+var (
+	validDistTarget = regexp.MustCompile(`^dist-(([a-z0-9]+)-([a-z0-9]+))$`)
+	manifestSection = regexp.MustCompile(`^\s*\[\[?\s*([^\]\s]+)\s*\]\]?`)
+	manifestValue   = regexp.MustCompile(`^\s*(name|version)\s*=\s*"([^"]*)"`)
+)
+
+type CargoRunner struct {
+	OsArch    string
+	Triple    string
+	IsWindows bool
+	Binaries  []string
+}
+
+type manifest struct {
+	Name     string
+	Version  string
+	Binaries []string
+}
+
+func readManifest(ctx *types.Ctx) (*manifest, error) {
+	f, err := os.Open(filepath.Join(ctx.SrcDir, "Cargo.toml"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rv := &manifest{}
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := manifestSection.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		}
+
+		m := manifestValue.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		switch section {
+		case "package":
+			if m[1] == "name" {
+				rv.Name = m[2]
+			} else {
+				rv.Version = m[2]
+			}
+		case "bin":
+			if m[1] == "name" {
+				rv.Binaries = append(rv.Binaries, m[2])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// cargo auto-discovers binaries if no [[bin]] section is declared
+	if len(rv.Binaries) == 0 {
+		if _, err := os.Stat(filepath.Join(ctx.SrcDir, "src", "main.rs")); err == nil {
+			rv.Binaries = append(rv.Binaries, rv.Name)
+		}
+		if files, err := ioutil.ReadDir(filepath.Join(ctx.SrcDir, "src", "bin")); err == nil {
+			for _, fileInfo := range files {
+				if fileInfo.Mode().IsRegular() && strings.HasSuffix(fileInfo.Name(), ".rs") {
+					rv.Binaries = append(rv.Binaries, strings.TrimSuffix(fileInfo.Name(), ".rs"))
+				}
+			}
+		}
+	}
+
+	return rv, nil
+}
+
+func supportedOsArch() []string {
+	rv := []string{}
+	for osArch := range distTriples {
+		rv = append(rv, osArch)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func (r *CargoRunner) Name() string {
+	return "cargo"
+}
+
+func (r *CargoRunner) ParallelSafe() bool {
+	return true
+}
+
+func (r *CargoRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *CargoRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "Cargo.toml")
+}
+
+func (r *CargoRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	m, err := readManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	projectName := m.Name
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+
+	// version may be inherited from a workspace
+	projectVersion := m.Version
+	if projectVersion == "" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	r.Binaries = m.Binaries
+
+	return &types.Project{Name: projectName, Version: projectVersion}, nil
+}
+
+func (r *CargoRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	targetDir := filepath.Join(ctx.BuildDir, "target")
+
+	if ctx.TargetName == "distcheck" {
+		cmd := exec.Command("cargo", append([]string{"test", "--target-dir", targetDir}, args...)...)
+		cmd.Dir = ctx.SrcDir
+		return executils.Run(cmd)
+	}
+
+	matches := validDistTarget.FindStringSubmatch(ctx.TargetName)
+	if matches == nil {
+		return fmt.Errorf("Error: Target not supported for cargo: %s", ctx.TargetName)
+	}
+
+	triple, found := distTriples[matches[1]]
+	if !found {
+		return fmt.Errorf("Error: Unsupported dist target for cargo: %s (supported: %s)", ctx.TargetName, strings.Join(supportedOsArch(), ", "))
+	}
+
+	r.OsArch = matches[1]
+	r.Triple = triple
+	r.IsWindows = matches[2] == "windows"
+
+	cargoArgs := append([]string{"build", "--release", "--target", triple, "--target-dir", targetDir}, args...)
+	cmd := exec.Command("cargo", cargoArgs...)
+	cmd.Dir = ctx.SrcDir
+	return executils.Run(cmd)
+}
+
+func (r *CargoRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	if r.Triple == "" {
+		return nil, nil
+	}
+
+	releaseDir := filepath.Join(ctx.BuildDir, "target", r.Triple, "release")
+
+	toCompress := []string{}
+
+	fileExtension := "tar.gz"
+	if r.IsWindows {
+		fileExtension = "zip"
+	}
+	filePrefix := fmt.Sprintf("%s-%s-%s", proj.Name, r.OsArch, proj.Version)
+	fileName := fmt.Sprintf("%s.%s", filePrefix, fileExtension)
+
+	for _, binaryName := range r.Binaries {
+		if r.IsWindows {
+			binaryName = fmt.Sprintf("%s.exe", binaryName)
+		}
+		toCompress = append(toCompress, binaryName)
+	}
+
+	// nothing is written on dry-run, binaries were not even built
+	if plan.Enabled() {
+		if fs.FindLicense(ctx.SrcDir) != "" {
+			toCompress = append(toCompress, "license.txt")
+		}
+		if fs.FindReadme(ctx.SrcDir) != "" {
+			toCompress = append(toCompress, "readme.txt")
+		}
+		compress.Plan(fileExtension, ctx.BuildDir, filePrefix, toCompress)
+		return []string{fileName}, nil
+	}
+
+	for _, binaryName := range toCompress {
+		if err := fs.CopyFile(filepath.Join(releaseDir, binaryName), filepath.Join(ctx.BuildDir, binaryName)); err != nil {
+			return nil, err
+		}
+	}
+
+	license := fs.FindLicense(ctx.SrcDir)
+	if len(license) > 0 {
+		licenseSrc := filepath.Join(ctx.SrcDir, license)
+		licenseDst := filepath.Join(ctx.BuildDir, "license.txt")
+		if err := fs.CopyFile(licenseSrc, licenseDst); err != nil {
+			return nil, err
+		}
+		toCompress = append(toCompress, "license.txt")
+	}
+
+	readme := fs.FindReadme(ctx.SrcDir)
+	if len(readme) > 0 {
+		readmeSrc := filepath.Join(ctx.SrcDir, readme)
+		readmeDst := filepath.Join(ctx.BuildDir, "readme.txt")
+		if err := fs.CopyFile(readmeSrc, readmeDst); err != nil {
+			return nil, err
+		}
+		toCompress = append(toCompress, "readme.txt")
+	}
+
+	f, err := os.Create(filepath.Join(ctx.BuildDir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if r.IsWindows {
+		if err := compress.Zip(ctx.BuildDir, filePrefix, toCompress, f); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := compress.TarGzip(ctx.BuildDir, filePrefix, toCompress, f); err != nil {
+			return nil, err
+		}
+	}
+
+	return []string{fileName}, nil
+}
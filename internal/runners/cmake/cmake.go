@@ -0,0 +1,164 @@
+package cmake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+var cpackExts = []string{
+	"tar.gz",
+	"tgz",
+	"tar.bz2",
+	"tbz2",
+	"tar.xz",
+	"txz",
+	"tar.zst",
+	"zip",
+	"7z",
+	"deb",
+	"rpm",
+	"sh",
+	"dmg",
+	"exe",
+	"msi",
+}
+
+var (
+	cacheNameVersion  = regexp.MustCompile(`(?m)^CMAKE_PROJECT_(NAME|VERSION):[A-Z]+=(.*)$`)
+	projectCall       = regexp.MustCompile(`(?is)\bproject\s*\(\s*([A-Za-z0-9_.+-]+)([^)]*)\)`)
+	projectVersionArg = regexp.MustCompile(`(?i)\bVERSION\s+"?([0-9][0-9A-Za-z.+-]*)`)
+)
+
+type CMakeRunner struct{}
+
+func getCMakeProject(ctx *types.Ctx) *types.Project {
+	projectName := ""
+	projectVersion := ""
+
+	// CMakeCache.txt only includes the version for cmake >= 3.12
+	if content, err := ioutil.ReadFile(filepath.Join(ctx.BuildDir, "CMakeCache.txt")); err == nil {
+		for _, match := range cacheNameVersion.FindAllStringSubmatch(string(content), -1) {
+			if match[1] == "NAME" {
+				projectName = strings.TrimSpace(match[2])
+			} else if match[1] == "VERSION" {
+				projectVersion = strings.TrimSpace(match[2])
+			}
+		}
+	}
+
+	if projectName == "" || projectVersion == "" {
+		if content, err := ioutil.ReadFile(filepath.Join(ctx.SrcDir, "CMakeLists.txt")); err == nil {
+			if match := projectCall.FindStringSubmatch(string(content)); match != nil {
+				if projectName == "" {
+					projectName = match[1]
+				}
+				if projectVersion == "" {
+					if m := projectVersionArg.FindStringSubmatch(match[2]); m != nil {
+						projectVersion = m[1]
+					}
+				}
+			}
+		}
+	}
+
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+	if projectVersion == "" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	return &types.Project{Name: projectName, Version: projectVersion}
+}
+
+func (r *CMakeRunner) Name() string {
+	return "cmake"
+}
+
+func (r *CMakeRunner) ParallelSafe() bool {
+	return true
+}
+
+func (r *CMakeRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *CMakeRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "CMakeLists.txt")
+}
+
+func (r *CMakeRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	cmd := exec.Command("cmake", append(append([]string{}, args...), ctx.SrcDir)...)
+	cmd.Dir = ctx.BuildDir
+	if err := executils.Run(cmd); err != nil {
+		return nil, err
+	}
+
+	return getCMakeProject(ctx), nil
+}
+
+func (r *CMakeRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	jobs := fmt.Sprintf("%d", runtime.NumCPU()+1)
+
+	buildArgs := []string{"--build", ".", "--parallel", jobs}
+
+	switch ctx.TargetName {
+	case "build", "all":
+		buildArgs = append(buildArgs, args...)
+
+	case "test":
+		cmd := exec.Command("cmake", buildArgs...)
+		cmd.Dir = ctx.BuildDir
+		if err := executils.Run(cmd); err != nil {
+			return err
+		}
+
+		cmd = exec.Command("ctest", append([]string{"--output-on-failure", "-j", jobs}, args...)...)
+		cmd.Dir = ctx.BuildDir
+		return executils.Run(cmd)
+
+	default:
+		buildArgs = append(append(buildArgs, "--target", ctx.TargetName), args...)
+	}
+
+	cmd := exec.Command("cmake", buildArgs...)
+	cmd.Dir = ctx.BuildDir
+	return executils.Run(cmd)
+}
+
+func (r *CMakeRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	files, err := ioutil.ReadDir(ctx.BuildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var builtFiles []string
+	for _, fileInfo := range files {
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+		if !strings.HasPrefix(fileInfo.Name(), fmt.Sprintf("%s-", proj.Name)) {
+			continue
+		}
+		for _, ext := range cpackExts {
+			if strings.HasSuffix(fileInfo.Name(), fmt.Sprintf(".%s", ext)) {
+				builtFiles = append(builtFiles, fileInfo.Name())
+				break
+			}
+		}
+	}
+
+	return builtFiles, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/rafaelmartins/yatr/internal/executils"
 	"github.com/rafaelmartins/yatr/internal/fs"
 	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
@@ -32,9 +33,12 @@ func (d *DwtkRunner) Name() string {
 }
 
 func (d *DwtkRunner) Detect(ctx *types.Ctx) bool {
-	path := filepath.Join(ctx.SrcDir, "dwtk-config.mk")
-	_, err := os.Stat(path)
-	return err == nil
+	detected, _ := d.Explain(ctx)
+	return detected
+}
+
+func (d *DwtkRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "dwtk-config.mk")
 }
 
 func (d *DwtkRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
@@ -44,6 +48,46 @@ func (d *DwtkRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, e
 	}, nil
 }
 
+// latestToolchain returns the url and file name of the latest toolchain
+// build. The server is not queried on dry-run, and placeholders are
+// returned.
+func latestToolchain() (string, string, error) {
+	latest := "https://distfiles.rgm.io/avr-toolchain/LATEST/"
+	if plan.Enabled() {
+		plan.Record(&plan.Operation{
+			Type:   "http",
+			Method: http.MethodGet,
+			URL:    latest,
+		})
+		file := fmt.Sprintf("avr-toolchain-%s-%s-LATEST.tar.xz", runtime.GOOS, runtime.GOARCH)
+		return "https://distfiles.rgm.io/avr-toolchain/avr-toolchain-LATEST/" + file, file, nil
+	}
+
+	resp, err := http.Get(latest)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", "", err
+	}
+
+	url := ""
+	file := ""
+	matches := reTarball.FindAllStringSubmatch(string(data), -1)
+	for _, m := range matches {
+		if m[2] == runtime.GOOS || m[3] == runtime.GOARCH {
+			url = fmt.Sprintf("https://distfiles.rgm.io/avr-toolchain/avr-toolchain-%s/%s", m[4], m[1])
+			file = m[1]
+		}
+	}
+	if url == "" {
+		return "", "", fmt.Errorf("no toolchain found")
+	}
+	return url, file, nil
+}
+
 func (d *DwtkRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
 	matches := reAvrTarget.FindStringSubmatch(ctx.TargetName)
 	if len(matches) == 0 {
@@ -55,29 +99,11 @@ func (d *DwtkRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) er
 
 	path := ""
 	if _, err := exec.LookPath("avr-gcc"); err != nil { // no toolchain found
-		resp, err := http.Get("https://distfiles.rgm.io/avr-toolchain/LATEST/")
-		if err != nil {
-			return err
-		}
-		data, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
+		url, file, err := latestToolchain()
 		if err != nil {
 			return err
 		}
 
-		url := ""
-		file := ""
-		matches := reTarball.FindAllStringSubmatch(string(data), -1)
-		for _, m := range matches {
-			if m[2] == runtime.GOOS || m[3] == runtime.GOARCH {
-				url = fmt.Sprintf("https://distfiles.rgm.io/avr-toolchain/avr-toolchain-%s/%s", m[4], m[1])
-				file = m[1]
-			}
-		}
-		if url == "" {
-			return fmt.Errorf("no toolchain found")
-		}
-
 		cmd := exec.Command("wget", url)
 		cmd.Dir = ctx.BuildDir
 		if err := executils.Run(cmd); err != nil {
@@ -129,6 +155,12 @@ func (d *DwtkRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) er
 		return err
 	}
 
+	// nothing was built on dry-run, and nothing must be written
+	if plan.Enabled() {
+		compress.Plan("tar.gz", root, d.Prefix, nil)
+		return nil
+	}
+
 	toCompress := []string{}
 
 	files, err := ioutil.ReadDir(root)
@@ -2,6 +2,8 @@ package golang
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,69 +11,163 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rafaelmartins/yatr/internal/compress"
 	"github.com/rafaelmartins/yatr/internal/executils"
 	"github.com/rafaelmartins/yatr/internal/fs"
 	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
-var validOSArch = []string{
-	"android-arm",
-	"darwin-386",
-	"darwin-amd64",
-	"darwin-armv5",
-	"darwin-armv6",
-	"darwin-armv7",
-	"darwin-arm64",
-	"dragonfly-amd64",
-	"freebsd-386",
-	"freebsd-amd64",
-	"freebsd-armv5",
-	"freebsd-armv6",
-	"freebsd-armv7",
-	"linux-386",
-	"linux-amd64",
-	"linux-armv5",
-	"linux-armv6",
-	"linux-armv7",
-	"linux-arm64",
-	"linux-ppc64",
-	"linux-ppc64le",
-	"linux-mips",
-	"linux-mipsle",
-	"linux-mips64",
-	"linux-mips64le",
-	"linux-s390x",
-	"netbsd-386",
-	"netbsd-amd64",
-	"netbsd-armv5",
-	"netbsd-armv6",
-	"netbsd-armv7",
-	"openbsd-386",
-	"openbsd-amd64",
-	"openbsd-armv5",
-	"openbsd-armv6",
-	"openbsd-armv7",
-	"plan9-386",
-	"plan9-amd64",
-	"solaris-amd64",
-	"windows-386",
-	"windows-amd64",
+type archVariant struct {
+	name string
+	env  string
+}
+
+// archVariants are the names accepted in targets for GOARCH values with
+// variants, and the environment variables that select them.
+var archVariants = map[string][]archVariant{
+	"arm": {
+		{"armv5", "GOARM=5"},
+		{"armv6", "GOARM=6"},
+		{"armv7", "GOARM=7"},
+	},
+	"amd64": {
+		{"amd64v1", "GOAMD64=v1"},
+		{"amd64v2", "GOAMD64=v2"},
+		{"amd64v3", "GOAMD64=v3"},
+		{"amd64v4", "GOAMD64=v4"},
+	},
+	"386":      {{"386sf", "GO386=softfloat"}},
+	"mips":     {{"mipssf", "GOMIPS=softfloat"}},
+	"mipsle":   {{"mipslesf", "GOMIPS=softfloat"}},
+	"mips64":   {{"mips64sf", "GOMIPS64=softfloat"}},
+	"mips64le": {{"mips64lesf", "GOMIPS64=softfloat"}},
+}
+
+type platform struct {
+	GOOS       string `json:"GOOS"`
+	GOARCH     string `json:"GOARCH"`
+	FirstClass bool   `json:"FirstClass"`
+}
+
+var (
+	platformsOnce sync.Once
+	platforms     []*platform
+	platformsErr  error
+)
+
+// getPlatforms returns the platforms supported by the go toolchain.
+func getPlatforms() ([]*platform, error) {
+	platformsOnce.Do(func() {
+		var out bytes.Buffer
+		cmd := exec.Command("go", "tool", "dist", "list", "-json")
+		cmd.Stdout = &out
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			platformsErr = fmt.Errorf("Error: Failed to list platforms supported by go: %s", err)
+			return
+		}
+		if err := json.Unmarshal(out.Bytes(), &platforms); err != nil {
+			platformsErr = fmt.Errorf("Error: Failed to parse platforms supported by go: %s", err)
+		}
+	})
+	return platforms, platformsErr
+}
+
+// supportedOSArch returns the platform names accepted in targets.
+func supportedOSArch() ([]string, error) {
+	ps, err := getPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []string{}
+	for _, p := range ps {
+		rv = append(rv, fmt.Sprintf("%s-%s", p.GOOS, p.GOARCH))
+		for _, v := range archVariants[p.GOARCH] {
+			rv = append(rv, fmt.Sprintf("%s-%s", p.GOOS, v.name))
+		}
+	}
+	return rv, nil
+}
+
+// firstClassOSArch returns the first class ports of the go toolchain, that
+// are built by `dist-all` if no platforms are configured.
+func firstClassOSArch() ([]string, error) {
+	ps, err := getPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []string{}
+	for _, p := range ps {
+		if p.FirstClass {
+			rv = append(rv, fmt.Sprintf("%s-%s", p.GOOS, p.GOARCH))
+		}
+	}
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("Error: No first class ports reported by go, golang.platforms must be set")
+	}
+	return rv, nil
+}
+
+// platformEnv returns the environment variables to build for goos and
+// arch, that may be a GOARCH value or one of its variants.
+func platformEnv(goos string, arch string) ([]string, error) {
+	ps, err := getPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range ps {
+		if p.GOOS != goos {
+			continue
+		}
+		env := []string{
+			fmt.Sprintf("GOOS=%s", p.GOOS),
+			fmt.Sprintf("GOARCH=%s", p.GOARCH),
+		}
+		if p.GOARCH == arch {
+			return env, nil
+		}
+		for _, v := range archVariants[p.GOARCH] {
+			if v.name == arch {
+				return append(env, v.env), nil
+			}
+		}
+	}
+
+	supported, err := supportedOSArch()
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("Error: Unsupported platform for golang: %s-%s, supported platforms: %s", goos, arch, strings.Join(supported, ", "))
 }
 
 var validDistTarget = regexp.MustCompile(`^dist-(([a-z0-9]+)-([a-z0-9]+))$`)
 var mainPackage = regexp.MustCompile(`^[ \t]*package[ \t]+main[ \t]*$`)
 
-type GolangRunner struct {
-	GoTool    string
-	IsWindows bool
+// Dist is a platform built by a dist target, in its own directory.
+type Dist struct {
 	OsArch    string
+	IsWindows bool
+	Dir       string
 	Binaries  []string
 }
 
+type GolangRunner struct {
+	Config    types.Golang
+	GoTool    string
+	Dists     []*Dist
+	BuildTime time.Time
+}
+
 func supportModules() bool {
 	cmd := exec.Command("go", "help", "mod")
 	cmd.Stdout = ioutil.Discard
@@ -79,20 +175,24 @@ func supportModules() bool {
 	return cmd.Run() == nil
 }
 
-func generateFullLicense(ctx *types.Ctx) (bool, error) {
+// vendorDependencies creates the vendor directory if not available, that is
+// required to collect the licenses of the dependencies.
+func vendorDependencies(ctx *types.Ctx) error {
 	gomodFile := filepath.Join(ctx.SrcDir, "go.mod")
 	vendorDir := filepath.Join(ctx.SrcDir, "vendor")
 
-	// create vendor directory if not available
 	if _, err := os.Stat(gomodFile); err == nil {
 		if _, err := os.Stat(vendorDir); os.IsNotExist(err) {
 			cmd := exec.Command("go", "mod", "vendor")
 			cmd.Dir = ctx.SrcDir
-			if err := executils.Run(cmd); err != nil {
-				return false, err
-			}
+			return executils.Run(cmd)
 		}
 	}
+	return nil
+}
+
+func generateFullLicense(ctx *types.Ctx, dir string) (bool, error) {
+	vendorDir := filepath.Join(ctx.SrcDir, "vendor")
 
 	// get main license
 	mainLicense := fs.FindLicense(ctx.SrcDir)
@@ -100,7 +200,7 @@ func generateFullLicense(ctx *types.Ctx) (bool, error) {
 		return false, nil
 	}
 
-	f, err := os.Create(filepath.Join(ctx.BuildDir, "license.txt"))
+	f, err := os.Create(filepath.Join(dir, "license.txt"))
 	if err != nil {
 		return false, err
 	}
@@ -141,6 +241,71 @@ func generateFullLicense(ctx *types.Ctx) (bool, error) {
 	return true, nil
 }
 
+func (r *GolangRunner) reproducible() bool {
+	return r.Config.Reproducible
+}
+
+// buildTime returns the time from SOURCE_DATE_EPOCH, if set. Otherwise
+// reproducible builds use the commit time, and other builds use the
+// current time.
+func (r *GolangRunner) buildTime(ctx *types.Ctx) (time.Time, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		ts, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Error: Invalid SOURCE_DATE_EPOCH: %s", epoch)
+		}
+		return time.Unix(ts, 0).UTC(), nil
+	}
+	if r.reproducible() {
+		return git.CommitTime(ctx.SrcDir)
+	}
+	return time.Now().UTC(), nil
+}
+
+// versionLdflags returns the `-X` linker flags enabled by the config.
+func (r *GolangRunner) versionLdflags(ctx *types.Ctx, proj *types.Project, osArch string, date time.Time) []string {
+	rv := []string{}
+	vars := []struct {
+		name  string
+		value func() string
+	}{
+		{r.Config.VersionVar, func() string { return proj.Version }},
+		{r.Config.CommitVar, func() string { return git.Commit(ctx.SrcDir) }},
+		{r.Config.DateVar, func() string { return date.Format(time.RFC3339) }},
+		{r.Config.TargetVar, func() string { return osArch }},
+	}
+	for _, v := range vars {
+		if v.name != "" {
+			rv = append(rv, fmt.Sprintf("-X=%s=%s", v.name, v.value()))
+		}
+	}
+	return rv
+}
+
+// addLdflags adds flags to the -ldflags argument found in args, because the
+// go tool only uses the last one, or appends a new one.
+func addLdflags(args []string, flags []string) []string {
+	if len(flags) == 0 {
+		return args
+	}
+
+	rv := append([]string{}, args...)
+	for i := len(rv) - 1; i >= 0; i-- {
+		arg := strings.TrimPrefix(rv[i], "-")
+		if arg == "-ldflags" || arg == "ldflags" {
+			if i+1 < len(rv) {
+				rv[i+1] = strings.Join(append([]string{rv[i+1]}, flags...), " ")
+				return rv
+			}
+		}
+		if strings.HasPrefix(arg, "-ldflags=") || strings.HasPrefix(arg, "ldflags=") {
+			rv[i] = strings.Join(append([]string{rv[i]}, flags...), " ")
+			return rv
+		}
+	}
+	return append(rv, "-ldflags="+strings.Join(flags, " "))
+}
+
 func getMainPackages(ctx *types.Ctx) []string {
 	rv := []string{}
 
@@ -148,10 +313,13 @@ func getMainPackages(ctx *types.Ctx) []string {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() && info.Name() == "vendor" {
-			return filepath.SkipDir
+		// directories ignored by the go tool
+		if info.IsDir() && path != ctx.SrcDir {
+			if name := info.Name(); name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
 		}
-		if info.Mode().IsRegular() && strings.HasSuffix(path, ".go") {
+		if info.Mode().IsRegular() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
 			f, err := os.Open(path)
 			if err != nil {
 				return err
@@ -190,23 +358,23 @@ func (r *GolangRunner) Name() string {
 	return "golang"
 }
 
+func (r *GolangRunner) ParallelSafe() bool {
+	return true
+}
+
 func (r *GolangRunner) Detect(ctx *types.Ctx) bool {
-	found := false
+	detected, _ := r.Explain(ctx)
+	return detected
+}
 
-	if !func() bool {
-		if ctx.TargetName == "distcheck" {
-			return true
-		}
-		if strings.HasPrefix(ctx.TargetName, "dist-") {
-			return true
-		}
-		return false
-	}() {
-		return false
+func (r *GolangRunner) Explain(ctx *types.Ctx) (bool, string) {
+	if ctx.TargetName != "distcheck" && !strings.HasPrefix(ctx.TargetName, "dist-") {
+		return false, "target name is not distcheck nor dist-*"
 	}
 
+	found := ""
 	filepath.Walk(ctx.SrcDir, func(path string, info os.FileInfo, err error) error {
-		if found {
+		if found != "" {
 			return nil
 		}
 
@@ -219,13 +387,19 @@ func (r *GolangRunner) Detect(ctx *types.Ctx) bool {
 		}
 
 		if filepath.Ext(info.Name()) == ".go" {
-			found = true
+			found = path
 		}
 
 		return nil
 	})
 
-	return found
+	if found == "" {
+		return false, "no .go files found"
+	}
+	if rel, err := filepath.Rel(ctx.SrcDir, found); err == nil {
+		found = rel
+	}
+	return true, fmt.Sprintf("target name matches, %s found", found)
 }
 
 func (r *GolangRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
@@ -239,9 +413,109 @@ func (r *GolangRunner) Configure(ctx *types.Ctx, args []string) (*types.Project,
 		os.Setenv("GO111MODULE", "on")
 	}
 
+	// the source directory must only be modified by the configure step, that
+	// never runs in parallel with other steps
+	if strings.HasPrefix(ctx.TargetName, "dist-") {
+		if err := vendorDependencies(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	return &types.Project{Name: projectName, Version: projectVersion}, nil
 }
 
+// selectMainPackages returns the main packages to build, that are all the
+// main packages found, unless golang.commands is set. Binaries are named
+// after their directories, and must be unique.
+func (r *GolangRunner) selectMainPackages(ctx *types.Ctx) ([]string, error) {
+	found := getMainPackages(ctx)
+
+	rel := func(dir string) string {
+		if relDir, err := filepath.Rel(ctx.SrcDir, dir); err == nil {
+			return filepath.ToSlash(relDir)
+		}
+		return dir
+	}
+
+	rv := found
+	if len(r.Config.Commands) > 0 {
+		rv = []string{}
+		for _, command := range r.Config.Commands {
+			dir := ""
+			for _, f := range found {
+				if rel(f) == path.Clean(command) {
+					dir = f
+					break
+				}
+			}
+			if dir == "" {
+				available := []string{}
+				for _, f := range found {
+					available = append(available, rel(f))
+				}
+				return nil, fmt.Errorf("Error: Command not found for golang: %s, available commands: %s", command, strings.Join(available, ", "))
+			}
+			rv = append(rv, dir)
+		}
+	}
+
+	names := map[string]string{}
+	for _, dir := range rv {
+		name := filepath.Base(dir)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("Error: Commands would build binaries with the same name: %s, %s", rel(other), rel(dir))
+		}
+		names[name] = dir
+	}
+
+	return rv, nil
+}
+
+// distOsArch returns the platforms built by the target, in the form
+// `<os>-<arch>`.
+func (r *GolangRunner) distOsArch(ctx *types.Ctx) ([]string, error) {
+	if ctx.TargetName == "dist-all" {
+		if len(r.Config.Platforms) > 0 {
+			return r.Config.Platforms, nil
+		}
+		return firstClassOSArch()
+	}
+
+	matches := validDistTarget.FindStringSubmatch(ctx.TargetName)
+	if matches == nil {
+		return nil, fmt.Errorf("Error: Invalid target name for golang: %s", ctx.TargetName)
+	}
+	return []string{matches[1]}, nil
+}
+
+func (r *GolangRunner) build(ctx *types.Ctx, proj *types.Project, d *Dist, dirs []string, goEnv []string, args []string) error {
+	ldflags := r.versionLdflags(ctx, proj, d.OsArch, r.BuildTime)
+	buildArgs := []string{r.GoTool, "-v", "-x"}
+	if r.reproducible() {
+		ldflags = append(ldflags, "-buildid=")
+		buildArgs = append(buildArgs, "-trimpath")
+	}
+	buildArgs = addLdflags(append(buildArgs, args...), ldflags)
+
+	for _, dir := range dirs {
+		binaryName := filepath.Base(dir)
+		if d.IsWindows {
+			binaryName += ".exe"
+		}
+
+		goArgs := append(append([]string{}, buildArgs...), "-o", filepath.Join(d.Dir, binaryName), dir)
+		cmd := exec.Command("go", goArgs...)
+		cmd.Dir = d.Dir
+		cmd.Env = append(os.Environ(), goEnv...)
+		if err := executils.Run(cmd); err != nil {
+			return err
+		}
+
+		d.Binaries = append(d.Binaries, binaryName)
+	}
+	return nil
+}
+
 func (r *GolangRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
 	if ctx.TargetName == "distcheck" {
 		r.GoTool = "test"
@@ -251,56 +525,83 @@ func (r *GolangRunner) Task(ctx *types.Ctx, proj *types.Project, args []string)
 		return fmt.Errorf("Error: Target not supported for golang: %s", ctx.TargetName)
 	}
 
-	r.IsWindows = false
+	r.Dists = nil
 
 	if r.GoTool == "build" {
-		matches := validDistTarget.FindStringSubmatch(ctx.TargetName)
-		if matches == nil {
-			return fmt.Errorf("Error: Invalid target name for golang: %s", ctx.TargetName)
+		osArchs, err := r.distOsArch(ctx)
+		if err != nil {
+			return err
 		}
 
-		r.OsArch = matches[1]
-
-		found := false
-		for _, elem := range validOSArch {
-			if r.OsArch == elem {
-				found = true
-			}
+		dirs, err := r.selectMainPackages(ctx)
+		if err != nil {
+			return err
 		}
-		if !found {
-			return fmt.Errorf("Error: Unsupported dist target for golang: %s", ctx.TargetName)
-		}
-
-		r.IsWindows = matches[2] == "windows"
-
-		goArch := matches[3]
-		goArm := ""
-		if strings.HasPrefix(matches[3], "armv") {
-			goArch = "arm"
-			goArm = matches[3][4:]
-		}
-
-		for _, dir := range getMainPackages(ctx) {
-			goArgs := append([]string{r.GoTool, "-v", "-x"}, args...)
-			goArgs = append(goArgs, dir)
-			cmd := exec.Command("go", goArgs...)
-			cmd.Dir = ctx.BuildDir
-			cmd.Env = append(
-				os.Environ(),
-				fmt.Sprintf("GOOS=%s", matches[2]),
-				fmt.Sprintf("GOARCH=%s", goArch),
-			)
-			if goArm != "" {
-				cmd.Env = append(
-					cmd.Env,
-					fmt.Sprintf("GOARM=%s", goArm),
-				)
+
+		// validate all the platforms before building any of them
+		envs := [][]string{}
+		for _, osArch := range osArchs {
+			parts := strings.SplitN(osArch, "-", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("Error: Invalid platform for golang: %s", osArch)
 			}
-			if err := executils.Run(cmd); err != nil {
+
+			goEnv, err := platformEnv(parts[0], parts[1])
+			if err != nil {
 				return err
 			}
+			envs = append(envs, goEnv)
+
+			// single platform targets are built in the build directory
+			dir := ctx.BuildDir
+			if ctx.TargetName == "dist-all" {
+				dir = filepath.Join(ctx.BuildDir, osArch)
+				if !plan.Enabled() {
+					os.MkdirAll(dir, 0777)
+				}
+			}
+
+			r.Dists = append(r.Dists, &Dist{
+				OsArch:    osArch,
+				IsWindows: parts[0] == "windows",
+				Dir:       dir,
+			})
+		}
+
+		r.BuildTime, err = r.buildTime(ctx)
+		if err != nil {
+			return err
+		}
+
+		// dry-run records operations in order, that requires building serially
+		if len(r.Dists) == 1 || plan.Enabled() {
+			for i, d := range r.Dists {
+				if err := r.build(ctx, proj, d, dirs, envs[i], args); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		errs := make([]error, len(r.Dists))
+		var wg sync.WaitGroup
+		for i, d := range r.Dists {
+			wg.Add(1)
+			go func(i int, d *Dist) {
+				defer wg.Done()
+				errs[i] = r.build(ctx, proj, d, dirs, envs[i], args)
+			}(i, d)
+		}
+		wg.Wait()
 
-			r.Binaries = append(r.Binaries, path.Base(dir))
+		failed := []string{}
+		for i, err := range errs {
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s (%s)", r.Dists[i].OsArch, err))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("Error: Failed to build platforms: %s", strings.Join(failed, ", "))
 		}
 	} else {
 		cmd := exec.Command("go", append([]string{r.GoTool, "-v"}, args...)...)
@@ -311,63 +612,99 @@ func (r *GolangRunner) Task(ctx *types.Ctx, proj *types.Project, args []string)
 	return nil
 }
 
-func (r *GolangRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
-	var builtFiles []string
-
-	if r.GoTool == "build" {
-
-		toCompress := []string{}
+// collect creates an archive named after name, with binaries from the dist
+// directory.
+func (r *GolangRunner) collect(ctx *types.Ctx, proj *types.Project, d *Dist, name string, binaries []string) (string, error) {
+	fileExtension := "tar.gz"
+	if d.IsWindows {
+		fileExtension = "zip"
+	}
+	filePrefix := fmt.Sprintf("%s-%s-%s", name, d.OsArch, proj.Version)
+	fileName := fmt.Sprintf("%s.%s", filePrefix, fileExtension)
 
-		for _, binaryName := range r.Binaries {
-			if r.IsWindows {
-				binaryName = fmt.Sprintf("%s.exe", proj.Name)
-			}
-			toCompress = append(toCompress, binaryName)
-		}
+	toCompress := append([]string{}, binaries...)
 
-		license, err := generateFullLicense(ctx)
-		if err != nil {
-			return nil, err
-		}
-		if license {
+	// nothing is written on dry-run, not even the license and readme files
+	if plan.Enabled() {
+		if fs.FindLicense(ctx.SrcDir) != "" {
 			toCompress = append(toCompress, "license.txt")
 		}
-
-		readme := fs.FindReadme(ctx.SrcDir)
-		if len(readme) > 0 {
-			readmeSrc := filepath.Join(ctx.SrcDir, readme)
-			readmeDst := filepath.Join(ctx.BuildDir, "readme.txt")
-			if err := fs.CopyFile(readmeSrc, readmeDst); err != nil {
-				return nil, err
-			}
+		if fs.FindReadme(ctx.SrcDir) != "" {
 			toCompress = append(toCompress, "readme.txt")
 		}
+		compress.Plan(fileExtension, d.Dir, filePrefix, toCompress)
+		return fileName, nil
+	}
+
+	license, err := generateFullLicense(ctx, d.Dir)
+	if err != nil {
+		return "", err
+	}
+	if license {
+		toCompress = append(toCompress, "license.txt")
+	}
 
-		fileExtension := "tar.gz"
-		if r.IsWindows {
-			fileExtension = "zip"
+	readme := fs.FindReadme(ctx.SrcDir)
+	if len(readme) > 0 {
+		readmeSrc := filepath.Join(ctx.SrcDir, readme)
+		readmeDst := filepath.Join(d.Dir, "readme.txt")
+		if err := fs.CopyFile(readmeSrc, readmeDst); err != nil {
+			return "", err
 		}
-		filePrefix := fmt.Sprintf("%s-%s-%s", proj.Name, r.OsArch, proj.Version)
-		fileName := fmt.Sprintf("%s.%s", filePrefix, fileExtension)
+		toCompress = append(toCompress, "readme.txt")
+	}
 
-		filePath := filepath.Join(ctx.BuildDir, fileName)
-		f, err := os.Create(filePath)
-		if err != nil {
-			return nil, err
+	filePath := filepath.Join(ctx.BuildDir, fileName)
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if r.reproducible() {
+		if d.IsWindows {
+			err = compress.ZipReproducible(d.Dir, filePrefix, toCompress, r.BuildTime, f)
+		} else {
+			err = compress.TarGzipReproducible(d.Dir, filePrefix, toCompress, r.BuildTime, f)
+		}
+	} else {
+		if d.IsWindows {
+			err = compress.Zip(d.Dir, filePrefix, toCompress, f)
+		} else {
+			err = compress.TarGzip(d.Dir, filePrefix, toCompress, f)
 		}
-		defer f.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
 
-		if r.IsWindows {
-			if err := compress.Zip(ctx.BuildDir, filePrefix, toCompress, f); err != nil {
-				return nil, err
+func (r *GolangRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	var builtFiles []string
+
+	if r.GoTool == "build" {
+
+		for _, d := range r.Dists {
+			if !r.Config.ArchivePerCommand {
+				fileName, err := r.collect(ctx, proj, d, proj.Name, d.Binaries)
+				if err != nil {
+					return nil, err
+				}
+				builtFiles = append(builtFiles, fileName)
+				continue
 			}
-		} else {
-			if err := compress.TarGzip(ctx.BuildDir, filePrefix, toCompress, f); err != nil {
-				return nil, err
+
+			for _, binaryName := range d.Binaries {
+				name := strings.TrimSuffix(binaryName, ".exe")
+				fileName, err := r.collect(ctx, proj, d, name, []string{binaryName})
+				if err != nil {
+					return nil, err
+				}
+				builtFiles = append(builtFiles, fileName)
 			}
 		}
-
-		builtFiles = []string{fileName}
 	}
 
 	return builtFiles, nil
@@ -0,0 +1,484 @@
+package golang
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// newSrcDir creates a project named `project` with a cmd/ layout, and main
+// packages that must be ignored. The parent directory must be removed.
+func newSrcDir(t *testing.T, extra ...string) string {
+	tmpDir, err := ioutil.TempDir("", "yatr-golang-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmpDir, "project")
+
+	files := map[string]string{
+		"go.mod":                 "module foo\n",
+		"main.go":                "package main\n\nfunc main() {}\n",
+		"cmd/a/main.go":          "package main\n\nfunc main() {}\n",
+		"cmd/b/main.go":          "package main\n\nfunc main() {}\n",
+		"testdata/c/main.go":     "package main\n\nfunc main() {}\n",
+		"lib/lib.go":             "package lib\n",
+		"lib/main_test.go":       "package main\n",
+		"vendor/d/main.go":       "package main\n\nfunc main() {}\n",
+		".hidden/e/main.go":      "package main\n\nfunc main() {}\n",
+		"_ignored/f/main.go":     "package main\n\nfunc main() {}\n",
+		"internal/x/x.go":        "package x\n",
+		"internal/x/x_test.go":   "package x\n",
+		"internal/x/y/y.go":      "package y\n",
+		"internal/x/y/y_test.go": "package main\n",
+	}
+	for _, e := range extra {
+		files[e] = "package main\n\nfunc main() {}\n"
+	}
+
+	for name, content := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func relDirs(t *testing.T, srcDir string, dirs []string) []string {
+	rv := []string{}
+	for _, dir := range dirs {
+		r, err := filepath.Rel(srcDir, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv = append(rv, filepath.ToSlash(r))
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func TestGetMainPackages(t *testing.T) {
+	srcDir := newSrcDir(t)
+	defer os.RemoveAll(filepath.Dir(srcDir))
+
+	got := relDirs(t, srcDir, getMainPackages(&types.Ctx{SrcDir: srcDir}))
+	expected := []string{".", "cmd/a", "cmd/b"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestSelectMainPackages(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    []string
+		commands []string
+		expected []string
+		err      string
+	}{
+		{"all", nil, nil, []string{".", "cmd/a", "cmd/b"}, ""},
+		{"one", nil, []string{"cmd/a"}, []string{"cmd/a"}, ""},
+		{"root", nil, []string{"."}, []string{"."}, ""},
+		{"unclean", nil, []string{"./cmd/b/"}, []string{"cmd/b"}, ""},
+		{"not found", nil, []string{"cmd/c"}, nil, "Command not found for golang: cmd/c, available commands: "},
+		{"testdata", nil, []string{"testdata/c"}, nil, "Command not found for golang: testdata/c"},
+		{"duplicated", []string{"tools/a/main.go"}, nil, nil, "Commands would build binaries with the same name: "},
+		{"duplicated not selected", []string{"tools/a/main.go"}, []string{"cmd/a", "cmd/b"}, []string{"cmd/a", "cmd/b"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srcDir := newSrcDir(t, test.extra...)
+			defer os.RemoveAll(filepath.Dir(srcDir))
+
+			r := &GolangRunner{Config: types.Golang{Commands: test.commands}}
+			dirs, err := r.selectMainPackages(&types.Ctx{SrcDir: srcDir})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relDirs(t, srcDir, dirs); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTaskCollectDryRun(t *testing.T) {
+	// commands are only planned, no go builds are executed
+	plan.Enable()
+	defer plan.Reset()
+
+	tests := []struct {
+		name              string
+		target            string
+		commands          []string
+		platforms         []string
+		archivePerCommand bool
+		binaries          map[string][]string
+		archives          []string
+	}{
+		{
+			name:     "linux",
+			target:   "dist-linux-amd64",
+			binaries: map[string][]string{"linux-amd64": {"a", "b", "project"}},
+			archives: []string{"foo-linux-amd64-1.0.tar.gz"},
+		},
+		{
+			name:     "windows",
+			target:   "dist-windows-amd64",
+			binaries: map[string][]string{"windows-amd64": {"a.exe", "b.exe", "project.exe"}},
+			archives: []string{"foo-windows-amd64-1.0.zip"},
+		},
+		{
+			name:     "windows commands",
+			target:   "dist-windows-amd64",
+			commands: []string{"cmd/b"},
+			binaries: map[string][]string{"windows-amd64": {"b.exe"}},
+			archives: []string{"foo-windows-amd64-1.0.zip"},
+		},
+		{
+			name:              "archive per command",
+			target:            "dist-all",
+			commands:          []string{"cmd/a", "cmd/b"},
+			platforms:         []string{"linux-armv7", "windows-arm64"},
+			archivePerCommand: true,
+			binaries: map[string][]string{
+				"linux-armv7":   {"a", "b"},
+				"windows-arm64": {"a.exe", "b.exe"},
+			},
+			archives: []string{
+				"a-linux-armv7-1.0.tar.gz",
+				"b-linux-armv7-1.0.tar.gz",
+				"a-windows-arm64-1.0.zip",
+				"b-windows-arm64-1.0.zip",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srcDir := newSrcDir(t)
+			defer os.RemoveAll(filepath.Dir(srcDir))
+
+			ctx := &types.Ctx{
+				TargetName: test.target,
+				SrcDir:     srcDir,
+				BuildDir:   filepath.Join(srcDir, "build", test.target),
+			}
+			proj := &types.Project{Name: "foo", Version: "1.0"}
+
+			r := &GolangRunner{
+				Config: types.Golang{
+					Commands:          test.commands,
+					Platforms:         test.platforms,
+					ArchivePerCommand: test.archivePerCommand,
+				},
+			}
+			if err := r.Task(ctx, proj, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			binaries := map[string][]string{}
+			for _, d := range r.Dists {
+				b := append([]string{}, d.Binaries...)
+				sort.Strings(b)
+				binaries[d.OsArch] = b
+			}
+			if !reflect.DeepEqual(binaries, test.binaries) {
+				t.Errorf("got binaries %q, expected %q", binaries, test.binaries)
+			}
+
+			archives, err := r.Collect(ctx, proj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(archives)
+			expected := append([]string{}, test.archives...)
+			sort.Strings(expected)
+			if !reflect.DeepEqual(archives, expected) {
+				t.Errorf("got archives %q, expected %q", archives, expected)
+			}
+		})
+	}
+}
+
+// archiveEntries returns the names of the files in a tar.gz or zip archive.
+func archiveEntries(t *testing.T, fn string) []string {
+	rv := []string{}
+
+	if strings.HasSuffix(fn, ".zip") {
+		r, err := zip.OpenReader(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			rv = append(rv, f.Name)
+		}
+		sort.Strings(rv)
+		return rv
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv = append(rv, hdr.Name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func TestTaskCollect(t *testing.T) {
+	if plan.Enabled() {
+		t.Fatal("dry-run enabled by another test")
+	}
+
+	tests := []struct {
+		name              string
+		target            string
+		commands          []string
+		archivePerCommand bool
+		binaries          []string
+		archives          map[string][]string
+	}{
+		{
+			name:     "linux",
+			target:   "dist-linux-amd64",
+			binaries: []string{"a", "b", "project"},
+			archives: map[string][]string{
+				"foo-linux-amd64-1.0.tar.gz": {
+					"foo-linux-amd64-1.0/a",
+					"foo-linux-amd64-1.0/b",
+					"foo-linux-amd64-1.0/license.txt",
+					"foo-linux-amd64-1.0/project",
+				},
+			},
+		},
+		{
+			name:              "windows archive per command",
+			target:            "dist-windows-amd64",
+			commands:          []string{"cmd/a", "cmd/b"},
+			archivePerCommand: true,
+			binaries:          []string{"a.exe", "b.exe"},
+			archives: map[string][]string{
+				"a-windows-amd64-1.0.zip": {
+					"a-windows-amd64-1.0/a.exe",
+					"a-windows-amd64-1.0/license.txt",
+				},
+				"b-windows-amd64-1.0.zip": {
+					"b-windows-amd64-1.0/b.exe",
+					"b-windows-amd64-1.0/license.txt",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srcDir := newSrcDir(t)
+			defer os.RemoveAll(filepath.Dir(srcDir))
+
+			if err := ioutil.WriteFile(filepath.Join(srcDir, "LICENSE"), []byte("license\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &types.Ctx{
+				TargetName: test.target,
+				SrcDir:     srcDir,
+				BuildDir:   filepath.Join(srcDir, "build", test.target),
+			}
+			if err := os.MkdirAll(ctx.BuildDir, 0777); err != nil {
+				t.Fatal(err)
+			}
+			proj := &types.Project{Name: "foo", Version: "1.0"}
+
+			r := &GolangRunner{
+				Config: types.Golang{
+					Commands:          test.commands,
+					ArchivePerCommand: test.archivePerCommand,
+				},
+			}
+			if err := r.Task(ctx, proj, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, binary := range test.binaries {
+				if _, err := os.Stat(filepath.Join(ctx.BuildDir, binary)); err != nil {
+					t.Errorf("binary not built: %s", err)
+				}
+			}
+
+			archives, err := r.Collect(ctx, proj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string][]string{}
+			for _, archive := range archives {
+				got[archive] = archiveEntries(t, filepath.Join(ctx.BuildDir, archive))
+			}
+			if !reflect.DeepEqual(got, test.archives) {
+				t.Errorf("got archives %q, expected %q", got, test.archives)
+			}
+		})
+	}
+}
+
+// setSourceDateEpoch sets SOURCE_DATE_EPOCH, because reproducible builds
+// use the commit time otherwise, and returns a function to restore it.
+func setSourceDateEpoch(value string) func() {
+	epoch, found := os.LookupEnv("SOURCE_DATE_EPOCH")
+	os.Setenv("SOURCE_DATE_EPOCH", value)
+	return func() {
+		if found {
+			os.Setenv("SOURCE_DATE_EPOCH", epoch)
+		} else {
+			os.Unsetenv("SOURCE_DATE_EPOCH")
+		}
+	}
+}
+
+func TestReproducibleBuildArgs(t *testing.T) {
+	plan.Enable()
+	defer plan.Reset()
+	defer setSourceDateEpoch("1500000000")()
+
+	srcDir := newSrcDir(t)
+	defer os.RemoveAll(filepath.Dir(srcDir))
+
+	ctx := &types.Ctx{
+		TargetName: "dist-linux-amd64",
+		SrcDir:     srcDir,
+		BuildDir:   filepath.Join(srcDir, "build", "dist-linux-amd64"),
+	}
+	proj := &types.Project{Name: "foo", Version: "1.0"}
+
+	r := &GolangRunner{
+		Config: types.Golang{
+			Reproducible: true,
+			Commands:     []string{"cmd/a"},
+		},
+	}
+	if err := r.Task(ctx, proj, []string{"-ldflags", "-s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	builds := 0
+	for _, op := range plan.Operations() {
+		if op.Type != "exec" || len(op.Args) < 2 || op.Args[1] != "build" {
+			continue
+		}
+		builds++
+
+		args := strings.Join(op.Args, " ")
+		if !strings.Contains(args, " -trimpath ") {
+			t.Errorf("-trimpath not found: %s", args)
+		}
+		if !strings.Contains(args, " -ldflags -s -buildid= ") {
+			t.Errorf("-buildid= not added to -ldflags: %s", args)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("got %d go build commands, expected 1", builds)
+	}
+}
+
+func TestReproducibleBuild(t *testing.T) {
+	if plan.Enabled() {
+		t.Fatal("dry-run enabled by another test")
+	}
+
+	defer setSourceDateEpoch("1500000000")()
+
+	for _, target := range []string{"dist-linux-amd64", "dist-windows-amd64"} {
+		t.Run(target, func(t *testing.T) {
+			// each build uses its own source directory, paths must not leak
+			// into the binaries
+			archives := [][]byte{}
+			for i := 0; i < 2; i++ {
+				srcDir := newSrcDir(t)
+				defer os.RemoveAll(filepath.Dir(srcDir))
+
+				if err := ioutil.WriteFile(filepath.Join(srcDir, "LICENSE"), []byte("license\n"), 0666); err != nil {
+					t.Fatal(err)
+				}
+
+				ctx := &types.Ctx{
+					TargetName: target,
+					SrcDir:     srcDir,
+					BuildDir:   filepath.Join(srcDir, "build", target),
+				}
+				if err := os.MkdirAll(ctx.BuildDir, 0777); err != nil {
+					t.Fatal(err)
+				}
+				proj := &types.Project{Name: "foo", Version: "1.0"}
+
+				r := &GolangRunner{
+					Config: types.Golang{
+						VersionVar:   "main.version",
+						DateVar:      "main.date",
+						Reproducible: true,
+					},
+				}
+				if err := r.Task(ctx, proj, nil); err != nil {
+					t.Fatal(err)
+				}
+				names, err := r.Collect(ctx, proj, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(names) != 1 {
+					t.Fatalf("got archives %q, expected one", names)
+				}
+
+				data, err := ioutil.ReadFile(filepath.Join(ctx.BuildDir, names[0]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				archives = append(archives, data)
+			}
+
+			if !bytes.Equal(archives[0], archives[1]) {
+				t.Error("archives are not byte-identical")
+			}
+		})
+	}
+}
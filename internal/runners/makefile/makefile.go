@@ -0,0 +1,141 @@
+package makefile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+var makeDistExts = []string{
+	"gz",
+	"bz2",
+	"xz",
+	"zip",
+	"lzip",
+	"zst",
+	"rpm",
+	"deb",
+}
+
+var makefiles = []string{
+	"GNUmakefile",
+	"makefile",
+	"Makefile",
+}
+
+// MakeRunner builds the project in the source directory, so it keeps the
+// files found there before the task, that are never collected.
+type MakeRunner struct {
+	files map[string]os.FileInfo
+}
+
+// listFiles returns the regular files in dir.
+func listFiles(dir string) (map[string]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := map[string]os.FileInfo{}
+	for _, fileInfo := range files {
+		if fileInfo.Mode().IsRegular() {
+			rv[fileInfo.Name()] = fileInfo
+		}
+	}
+	return rv, nil
+}
+
+// printVariable gets the value of a variable from a `print-%` rule, like:
+//
+//	print-%:
+//	        @echo $($*)
+//
+// The rule is not called on dry-run, because it may run arbitrary commands.
+func printVariable(ctx *types.Ctx, name string) string {
+	if plan.Enabled() {
+		return ""
+	}
+
+	cmd := exec.Command("make", "-s", "--no-print-directory", fmt.Sprintf("print-%s", name))
+	cmd.Dir = ctx.SrcDir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *MakeRunner) Name() string {
+	return "make"
+}
+
+func (r *MakeRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *MakeRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, makefiles...)
+}
+
+func (r *MakeRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	projectName := printVariable(ctx, "PN")
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+
+	projectVersion := printVariable(ctx, "PV")
+	if projectVersion == "" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	return &types.Project{Name: projectName, Version: projectVersion}, nil
+}
+
+func (r *MakeRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	jobs := fmt.Sprintf("-j%d", runtime.NumCPU()+1)
+	makeArgs := append(append([]string{jobs}, args...), ctx.TargetName)
+
+	files, err := listFiles(ctx.SrcDir)
+	if err != nil {
+		return err
+	}
+	r.files = files
+
+	cmd := exec.Command("make", makeArgs...)
+	cmd.Dir = ctx.SrcDir
+	return executils.Run(cmd)
+}
+
+// Collect copies the archives created or modified by the task from the
+// source directory. Files tracked by git are never collected, and nothing
+// is collected if the task was not executed, e.g. when replaced by a
+// task_script.
+func (r *MakeRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	if r.files == nil {
+		return nil, nil
+	}
+
+	tracked := git.TrackedFiles(ctx.SrcDir)
+
+	accept := func(fileInfo os.FileInfo) bool {
+		if !strings.HasPrefix(fileInfo.Name(), fmt.Sprintf("%s-", proj.Name)) || tracked[fileInfo.Name()] {
+			return false
+		}
+		old, found := r.files[fileInfo.Name()]
+		return !found || !old.ModTime().Equal(fileInfo.ModTime()) || old.Size() != fileInfo.Size()
+	}
+
+	// the source directory is not ours to modify, archives are copied
+	return fs.CollectArchives(ctx.SrcDir, ctx.BuildDir, makeDistExts, accept, true)
+}
@@ -0,0 +1,129 @@
+package meson
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+var mesonDistExts = []string{
+	"tar.xz",
+	"tar.xz.sha256sum",
+	"tar.gz",
+	"tar.gz.sha256sum",
+	"tar.bz2",
+	"tar.bz2.sha256sum",
+	"zip",
+	"zip.sha256sum",
+}
+
+var (
+	projectCall       = regexp.MustCompile(`(?s)\bproject\s*\(\s*'([^']+)'([^)]*)\)`)
+	projectVersionArg = regexp.MustCompile(`\bversion\s*:\s*'([^']+)'`)
+)
+
+type MesonRunner struct{}
+
+type projectInfo struct {
+	Version         string `json:"version"`
+	DescriptiveName string `json:"descriptive_name"`
+}
+
+func getMesonProject(ctx *types.Ctx) *types.Project {
+	projectName := ""
+	projectVersion := ""
+
+	cmd := exec.Command("meson", "introspect", "--projectinfo", ctx.BuildDir)
+	cmd.Dir = ctx.SrcDir
+	if out, err := cmd.Output(); err == nil {
+		info := projectInfo{}
+		if err := json.Unmarshal(out, &info); err == nil {
+			projectName = info.DescriptiveName
+			projectVersion = info.Version
+		}
+	}
+
+	// build directory is not configured on dry-run, parse meson.build instead
+	if projectName == "" {
+		if content, err := ioutil.ReadFile(filepath.Join(ctx.SrcDir, "meson.build")); err == nil {
+			if match := projectCall.FindStringSubmatch(string(content)); match != nil {
+				projectName = match[1]
+				if m := projectVersionArg.FindStringSubmatch(match[2]); m != nil {
+					projectVersion = m[1]
+				}
+			}
+		}
+	}
+
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+	if projectVersion == "" || projectVersion == "undefined" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	return &types.Project{Name: projectName, Version: projectVersion}
+}
+
+func (r *MesonRunner) Name() string {
+	return "meson"
+}
+
+func (r *MesonRunner) ParallelSafe() bool {
+	return true
+}
+
+func (r *MesonRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *MesonRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "meson.build")
+}
+
+func (r *MesonRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	setupArgs := append(append([]string{"setup"}, args...), ctx.BuildDir)
+
+	cmd := exec.Command("meson", setupArgs...)
+	cmd.Dir = ctx.SrcDir
+	if err := executils.Run(cmd); err != nil {
+		return nil, err
+	}
+
+	return getMesonProject(ctx), nil
+}
+
+func (r *MesonRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	var mesonArgs []string
+
+	switch ctx.TargetName {
+	case "dist":
+		mesonArgs = append([]string{"dist", "-C", ctx.BuildDir}, args...)
+	case "test":
+		mesonArgs = append([]string{"test", "-C", ctx.BuildDir}, args...)
+	case "build", "all":
+		mesonArgs = append([]string{"compile", "-C", ctx.BuildDir}, args...)
+	default:
+		mesonArgs = append(append([]string{"compile", "-C", ctx.BuildDir}, args...), ctx.TargetName)
+	}
+
+	cmd := exec.Command("meson", mesonArgs...)
+	cmd.Dir = ctx.SrcDir
+	return executils.Run(cmd)
+}
+
+func (r *MesonRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	if ctx.TargetName != "dist" {
+		return nil, nil
+	}
+	return fs.CollectArchives(filepath.Join(ctx.BuildDir, "meson-dist"), ctx.BuildDir, mesonDistExts, nil, false)
+}
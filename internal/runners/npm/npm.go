@@ -0,0 +1,110 @@
+package npm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+type NpmRunner struct{}
+
+type packageJSON struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (r *NpmRunner) Name() string {
+	return "npm"
+}
+
+func (r *NpmRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *NpmRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "package.json")
+}
+
+func (r *NpmRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	content, err := ioutil.ReadFile(filepath.Join(ctx.SrcDir, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := packageJSON{}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("Error: Failed to parse package.json: %s", err)
+	}
+
+	// use the same name as `npm pack`, scoped packages are renamed from
+	// `@scope/name` to `scope-name`
+	projectName := strings.Replace(strings.TrimPrefix(pkg.Name, "@"), "/", "-", -1)
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+
+	projectVersion := pkg.Version
+	if projectVersion == "" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	cmd := exec.Command("npm", append([]string{"ci"}, args...)...)
+	cmd.Dir = ctx.SrcDir
+	if err := executils.Run(cmd); err != nil {
+		return nil, err
+	}
+
+	return &types.Project{Name: projectName, Version: projectVersion}, nil
+}
+
+func (r *NpmRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	var npmArgs []string
+
+	switch ctx.TargetName {
+	case "distcheck":
+		npmArgs = append([]string{"test"}, args...)
+	case "dist":
+		npmArgs = append([]string{"pack", "--pack-destination", ctx.BuildDir}, args...)
+	default:
+		npmArgs = []string{"run", ctx.TargetName}
+		if len(args) > 0 {
+			npmArgs = append(append(npmArgs, "--"), args...)
+		}
+	}
+
+	cmd := exec.Command("npm", npmArgs...)
+	cmd.Dir = ctx.SrcDir
+	return executils.Run(cmd)
+}
+
+func (r *NpmRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	files, err := ioutil.ReadDir(ctx.BuildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var builtFiles []string
+	for _, fileInfo := range files {
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+		if !strings.HasPrefix(fileInfo.Name(), fmt.Sprintf("%s-", proj.Name)) {
+			continue
+		}
+		if strings.HasSuffix(fileInfo.Name(), ".tgz") {
+			builtFiles = append(builtFiles, fileInfo.Name())
+		}
+	}
+
+	return builtFiles, nil
+}
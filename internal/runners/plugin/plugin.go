@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/plugins"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// PluginRunner implements a runner with an external `yatr-runner-*`
+// executable. Its methods are `detect`, `configure`, `task` and `collect`,
+// and all of them receive a request like:
+//
+//	{"ctx": {...}, "project": {...}, "args": [...], "dry_run": false}
+//
+// `project` is not sent to `detect` and `configure`. Responses are
+// `{"detected": true, "evidence": "..."}` for `detect`, where evidence is
+// optional, `{"project": {"name": "...", "version": "..."}}` for
+// `configure`, `{"archives": [...]}` for `collect`, with archives relative
+// to the build directory, and `{}` for `task`. On dry-run, plugins must not
+// execute anything.
+type PluginRunner struct {
+	Plugin *plugins.Plugin
+}
+
+type request struct {
+	Ctx     *types.Ctx     `json:"ctx"`
+	Project *types.Project `json:"project,omitempty"`
+	Args    []string       `json:"args,omitempty"`
+	DryRun  bool           `json:"dry_run"`
+}
+
+func (r *PluginRunner) Name() string {
+	return r.Plugin.Name
+}
+
+func (r *PluginRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *PluginRunner) Explain(ctx *types.Ctx) (bool, string) {
+	resp := struct {
+		Detected bool   `json:"detected"`
+		Evidence string `json:"evidence"`
+	}{}
+	if err := r.Plugin.Call("detect", &request{Ctx: ctx, DryRun: plan.Enabled()}, &resp, false); err != nil {
+		log.Println("Warning: ", err)
+		return false, err.Error()
+	}
+	if resp.Evidence == "" {
+		resp.Evidence = "plugin " + r.Plugin.Path
+	}
+	return resp.Detected, resp.Evidence
+}
+
+func (r *PluginRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	resp := struct {
+		Project *types.Project `json:"project"`
+	}{}
+	if err := r.Plugin.Call("configure", &request{Ctx: ctx, Args: args, DryRun: plan.Enabled()}, &resp, true); err != nil {
+		return nil, err
+	}
+	if resp.Project == nil {
+		return nil, fmt.Errorf("plugin %s: configure: no project returned", r.Plugin.Name)
+	}
+	return resp.Project, nil
+}
+
+func (r *PluginRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	return r.Plugin.Call("task", &request{Ctx: ctx, Project: proj, Args: args, DryRun: plan.Enabled()}, nil, true)
+}
+
+func (r *PluginRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	resp := struct {
+		Archives []string `json:"archives"`
+	}{}
+	if err := r.Plugin.Call("collect", &request{Ctx: ctx, Project: proj, Args: args, DryRun: plan.Enabled()}, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Archives, nil
+}
@@ -0,0 +1,154 @@
+package python
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+var pythonDistExts = []string{
+	"tar.gz",
+	"whl",
+	"zip",
+}
+
+var (
+	pyprojectSection = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+	pyprojectValue   = regexp.MustCompile(`^\s*(name|version)\s*=\s*["']([^"']*)["']`)
+)
+
+type PythonRunner struct{}
+
+func getPython() string {
+	if p := os.Getenv("PYTHON"); p != "" {
+		return p
+	}
+	return "python3"
+}
+
+func readPyproject(ctx *types.Ctx) (string, string) {
+	f, err := os.Open(filepath.Join(ctx.SrcDir, "pyproject.toml"))
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	name := ""
+	version := ""
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := pyprojectSection.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSpace(m[1])
+			continue
+		}
+
+		if section != "project" && section != "tool.poetry" {
+			continue
+		}
+
+		if m := pyprojectValue.FindStringSubmatch(line); m != nil {
+			if m[1] == "name" {
+				name = m[2]
+			} else {
+				version = m[2]
+			}
+		}
+	}
+
+	return name, version
+}
+
+func readSetupPy(ctx *types.Ctx, arg string) string {
+	// running setup.py executes project code, not acceptable on dry-run
+	if plan.Enabled() {
+		return ""
+	}
+
+	if _, err := os.Stat(filepath.Join(ctx.SrcDir, "setup.py")); err != nil {
+		return ""
+	}
+
+	cmd := exec.Command(getPython(), "setup.py", arg)
+	cmd.Dir = ctx.SrcDir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	// setuptools may print warnings before the value
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func (r *PythonRunner) Name() string {
+	return "python"
+}
+
+func (r *PythonRunner) Detect(ctx *types.Ctx) bool {
+	detected, _ := r.Explain(ctx)
+	return detected
+}
+
+func (r *PythonRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return fs.DetectFile(ctx.SrcDir, "pyproject.toml", "setup.py")
+}
+
+func (r *PythonRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
+	// static metadata from pyproject.toml, then dynamic metadata from setup.py
+	projectName, projectVersion := readPyproject(ctx)
+	if projectName == "" {
+		projectName = readSetupPy(ctx, "--name")
+	}
+	if projectVersion == "" {
+		projectVersion = readSetupPy(ctx, "--version")
+	}
+
+	if projectName == "" {
+		projectName = path.Base(ctx.SrcDir)
+	}
+	if projectVersion == "" {
+		projectVersion = git.Version(ctx.SrcDir)
+	}
+
+	return &types.Project{Name: projectName, Version: projectVersion}, nil
+}
+
+func (r *PythonRunner) Task(ctx *types.Ctx, proj *types.Project, args []string) error {
+	var pythonArgs []string
+
+	switch ctx.TargetName {
+	case "distcheck":
+		pythonArgs = append([]string{"-m", "pytest"}, args...)
+	case "dist":
+		pythonArgs = append([]string{"-m", "build", "--sdist", "--wheel", "--outdir", filepath.Join(ctx.BuildDir, "dist")}, args...)
+		pythonArgs = append(pythonArgs, ctx.SrcDir)
+	default:
+		return fmt.Errorf("Error: Target not supported for python: %s", ctx.TargetName)
+	}
+
+	cmd := exec.Command(getPython(), pythonArgs...)
+	cmd.Dir = ctx.SrcDir
+	return executils.Run(cmd)
+}
+
+func (r *PythonRunner) Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error) {
+	if ctx.TargetName != "dist" {
+		return nil, nil
+	}
+	return fs.CollectArchives(filepath.Join(ctx.BuildDir, "dist"), ctx.BuildDir, pythonDistExts, nil, false)
+}
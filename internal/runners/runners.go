@@ -2,20 +2,34 @@ package runners
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/rafaelmartins/yatr/internal/executils"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/plugins"
 	"github.com/rafaelmartins/yatr/internal/runners/autotools"
+	"github.com/rafaelmartins/yatr/internal/runners/cargo"
+	"github.com/rafaelmartins/yatr/internal/runners/cmake"
 	"github.com/rafaelmartins/yatr/internal/runners/dwtk"
 	"github.com/rafaelmartins/yatr/internal/runners/golang"
+	"github.com/rafaelmartins/yatr/internal/runners/makefile"
+	"github.com/rafaelmartins/yatr/internal/runners/meson"
+	"github.com/rafaelmartins/yatr/internal/runners/npm"
+	"github.com/rafaelmartins/yatr/internal/runners/plugin"
+	"github.com/rafaelmartins/yatr/internal/runners/python"
 	"github.com/rafaelmartins/yatr/internal/runners/script"
 	"github.com/rafaelmartins/yatr/internal/types"
 )
 
+// Runner builds a project. Configure may modify the source directory,
+// because it never runs in parallel with the other steps of any target.
+// Task and Collect may only modify it if the runner is not ParallelSafe.
 type Runner interface {
 	Name() string
 	Detect(ctx *types.Ctx) bool
@@ -24,31 +38,150 @@ type Runner interface {
 	Collect(ctx *types.Ctx, proj *types.Project, args []string) ([]string, error)
 }
 
-var runners = []Runner{
-	&autotools.AutotoolsRunner{},
-	&golang.GolangRunner{},
-	&dwtk.DwtkRunner{},
-	&script.ScriptRunner{},
+// Explainer is implemented by runners that can tell why they detected a
+// project, or why not.
+type Explainer interface {
+	Explain(ctx *types.Ctx) (bool, string)
 }
 
-func Get(targetName string, srcDir string, buildDir string) (Runner, *types.Ctx) {
+// ParallelSafe is implemented by runners that build out of the source
+// directory, and never modify it after Configure. The Task and Collect
+// steps of their targets may run in parallel with other targets.
+type ParallelSafe interface {
+	ParallelSafe() bool
+}
+
+func IsParallelSafe(r Runner) bool {
+	p, ok := r.(ParallelSafe)
+	return ok && p.ParallelSafe()
+}
+
+// Options are the settings of the built-in runners, from the config. They
+// are given to the runners when created.
+type Options struct {
+	Golang types.Golang
+}
+
+// runners keep state between steps, so every target gets fresh instances
+var runners = []func(opts Options) Runner{
+	func(opts Options) Runner { return &autotools.AutotoolsRunner{} },
+	func(opts Options) Runner { return &golang.GolangRunner{Config: opts.Golang} },
+	func(opts Options) Runner { return &dwtk.DwtkRunner{} },
+	func(opts Options) Runner { return &cmake.CMakeRunner{} },
+	func(opts Options) Runner { return &meson.MesonRunner{} },
+	func(opts Options) Runner { return &cargo.CargoRunner{} },
+	func(opts Options) Runner { return &python.PythonRunner{} },
+	func(opts Options) Runner { return &npm.NpmRunner{} },
+	func(opts Options) Runner { return &makefile.MakeRunner{} },
+	func(opts Options) Runner { return &script.ScriptRunner{} },
+}
+
+// scriptTaskSkipped are runners that detect projects that used to fall back
+// to the script runner. They are not detected for targets with a
+// task_script, that would stop collecting the archives written by the
+// script, and must be selected with `runner:` for such targets.
+var scriptTaskSkipped = map[string]bool{
+	"cmake":  true,
+	"meson":  true,
+	"cargo":  true,
+	"python": true,
+	"npm":    true,
+	"make":   true,
+}
+
+var registered = []func() Runner{}
+
+// Register adds a runner, that has precedence over the built-in runners.
+// It must not be called while a pipeline is running.
+func Register(f func() Runner) {
+	registered = append(registered, f)
+}
+
+// getRunners returns the runner plugins found for srcDir, followed by the
+// registered runners and the built-in runners, created with opts.
+func getRunners(srcDir string, opts Options) []func() Runner {
+	rv := []func() Runner{}
+	for _, p := range plugins.Find("yatr-runner-", []string{filepath.Join(srcDir, ".yatr", "runners")}) {
+		p := p
+		rv = append(rv, func() Runner { return &plugin.PluginRunner{Plugin: p} })
+	}
+	rv = append(rv, registered...)
+	for _, f := range runners {
+		f := f
+		rv = append(rv, func() Runner { return f(opts) })
+	}
+	return rv
+}
+
+func Names(srcDir string) []string {
+	rv := []string{}
+	for _, f := range getRunners(srcDir, Options{}) {
+		rv = append(rv, f().Name())
+	}
+	return rv
+}
+
+// Detect evaluates every runner available for the target, in order of
+// precedence. Unlike Get, nothing is created in the build directory.
+func Detect(ctx *types.Ctx, taskScript bool, opts Options) []*types.Detection {
+	rv := []*types.Detection{}
+	for _, f := range getRunners(ctx.SrcDir, opts) {
+		v := f()
+		d := &types.Detection{Name: v.Name()}
+		if e, ok := v.(Explainer); ok {
+			d.Detected, d.Evidence = e.Explain(ctx)
+		} else {
+			d.Detected = v.Detect(ctx)
+		}
+		if d.Detected && taskScript && scriptTaskSkipped[d.Name] {
+			d.Detected = false
+			d.Evidence = fmt.Sprintf("skipped, target has task_script, set `runner: %s` to use it", d.Name)
+		}
+		rv = append(rv, d)
+	}
+	return rv
+}
+
+// Get returns the runner for the target. If runnerName is not empty, the
+// named runner is used without checking if it detects the project.
+// taskScript must be set if the target has a task_script.
+func Get(targetName string, srcDir string, buildDir string, runnerName string, taskScript bool, opts Options) (Runner, *types.Ctx, error) {
 	ctx := &types.Ctx{
 		TargetName: targetName,
 		SrcDir:     srcDir,
 		BuildDir:   buildDir,
 	}
 
-	// ensure build dir is clean
-	os.RemoveAll(ctx.BuildDir)
-	os.MkdirAll(ctx.BuildDir, 0777)
+	// ensure build dir is clean, but never touch it on dry-run
+	if !plan.Enabled() {
+		os.RemoveAll(ctx.BuildDir)
+		os.MkdirAll(ctx.BuildDir, 0777)
+	}
+
+	rs := getRunners(srcDir, opts)
 
-	for _, v := range runners {
-		if v.Detect(ctx) {
-			return v, ctx
+	if runnerName != "" {
+		for _, f := range rs {
+			if v := f(); v.Name() == runnerName {
+				return v, ctx, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("unknown runner %q, available runners: %s", runnerName, strings.Join(Names(srcDir), ", "))
+	}
+
+	for _, f := range rs {
+		v := f()
+		if !v.Detect(ctx) {
+			continue
+		}
+		if taskScript && scriptTaskSkipped[v.Name()] {
+			log.Printf("    Runner %s detected, but skipped because the target has task_script, set `runner: %s` to use it", v.Name(), v.Name())
+			continue
 		}
+		return v, ctx, nil
 	}
 
-	return nil, nil
+	return nil, nil, fmt.Errorf("No runner found for this project!")
 }
 
 func RunTargetScript(ctx *types.Ctx, proj *types.Project, taskScript string, taskArgs []string) error {
@@ -16,10 +16,18 @@ func (s *ScriptRunner) Name() string {
 	return "script"
 }
 
+func (s *ScriptRunner) ParallelSafe() bool {
+	return true
+}
+
 func (s *ScriptRunner) Detect(ctx *types.Ctx) bool {
 	return true
 }
 
+func (s *ScriptRunner) Explain(ctx *types.Ctx) (bool, string) {
+	return true, "always available"
+}
+
 func (s *ScriptRunner) Configure(ctx *types.Ctx, args []string) (*types.Project, error) {
 	projectName := path.Base(ctx.SrcDir)
 
@@ -0,0 +1,99 @@
+package tmpl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// Data is the data available to the config templates. Maps are used all the
+// way down, so that undefined values are reported as errors.
+type Data map[string]interface{}
+
+func env() map[string]string {
+	rv := map[string]string{}
+	for _, e := range os.Environ() {
+		if i := strings.Index(e, "="); i > 0 {
+			rv[e[:i]] = e[i+1:]
+		}
+	}
+	return rv
+}
+
+// NewData creates the template data for a target. Variables may use all the
+// other values, but not other variables.
+func NewData(ctx *types.Ctx, runnerName string, variables map[string]string) (Data, error) {
+	d := Data{
+		"Target": ctx.TargetName,
+		"Runner": runnerName,
+		"Env":    env(),
+		"Git": map[string]string{
+			"Version":     git.Version(ctx.SrcDir),
+			"Commit":      git.Commit(ctx.SrcDir),
+			"ShortCommit": git.ShortCommit(ctx.SrcDir),
+			"Branch":      git.Branch(ctx.SrcDir),
+			"Tag":         git.Tag(ctx.SrcDir),
+		},
+	}
+
+	names := []string{}
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := map[string]string{}
+	for _, name := range names {
+		v, err := Render(fmt.Sprintf("variables.%s", name), variables[name], d)
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = v
+	}
+	d["Vars"] = vars
+
+	return d, nil
+}
+
+// SetProject makes the project name and version available to templates as
+// {{.Name}} and {{.Version}}.
+func (d Data) SetProject(proj *types.Project) {
+	d["Name"] = proj.Name
+	d["Version"] = proj.Version
+}
+
+func Render(name string, text string, data Data) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	b := new(bytes.Buffer)
+	if err := t.Execute(b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func RenderAll(name string, texts []string, data Data) ([]string, error) {
+	rv := []string{}
+	for i, text := range texts {
+		v, err := Render(fmt.Sprintf("%s[%d]", name, i), text, data)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, v)
+	}
+	return rv, nil
+}
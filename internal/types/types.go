@@ -1,12 +1,47 @@
 package types
 
 type Ctx struct {
-	TargetName string
-	SrcDir     string
-	BuildDir   string
+	TargetName string `json:"target_name"`
+	SrcDir     string `json:"src_dir"`
+	BuildDir   string `json:"build_dir"`
+}
+
+// Golang is the configuration of the golang runner.
+type Golang struct {
+	// VersionVar, CommitVar, DateVar and TargetVar are names of string
+	// variables, like `main.version`, that are set with `-X` linker flags
+	// when building dist targets. Empty fields are not set.
+	VersionVar string `yaml:"version_var"`
+	CommitVar  string `yaml:"commit_var"`
+	DateVar    string `yaml:"date_var"`
+	TargetVar  string `yaml:"target_var"`
+
+	// Reproducible builds dist targets without paths and build ids, and
+	// archives them with normalized metadata.
+	Reproducible bool `yaml:"reproducible"`
+
+	// Platforms are the `<os>-<arch>` names built by the `dist-all` target,
+	// defaults to the first class ports.
+	Platforms []string `yaml:"platforms"`
+
+	// Commands are the directories of the main packages to ship, relative to
+	// the source directory, defaults to all of them.
+	Commands []string `yaml:"commands"`
+
+	// ArchivePerCommand creates an archive for each binary, instead of one
+	// with all of them.
+	ArchivePerCommand bool `yaml:"archive_per_command"`
 }
 
 type Project struct {
-	Name    string
-	Version string
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Detection is the result of detecting a runner or publisher, with the
+// evidence that supports it, if available.
+type Detection struct {
+	Name     string `json:"name"`
+	Detected bool   `json:"detected"`
+	Evidence string `json:"evidence,omitempty"`
 }
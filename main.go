@@ -1,152 +1,260 @@
 package main
 
 import (
-	"bytes"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"path"
-	"text/template"
-
-	"github.com/rafaelmartins/yatr/internal/config"
-	"github.com/rafaelmartins/yatr/internal/fs"
-	"github.com/rafaelmartins/yatr/internal/git"
-	"github.com/rafaelmartins/yatr/internal/publishers"
-	"github.com/rafaelmartins/yatr/internal/runners"
+	"strings"
+	"time"
+
+	"github.com/rafaelmartins/yatr/pkg/yatr"
 )
 
-func main() {
-	log.SetFlags(0)
-	log.SetPrefix("[YATR] >>> ")
+var (
+	parallel = flag.Bool("parallel", false, "run multiple targets in parallel, after configuring them serially. targets whose runners build in the source directory still run serially (output is interleaved)")
+	dryRun   = flag.Bool("dry-run", false, "print the operations the pipeline would execute, without executing them")
+	jsonPlan = flag.Bool("json", false, "print the dry-run operations as JSON")
+	confFile = flag.String("config", "", "config file to use, instead of YATR_CONFIG environment variable or .yatr.yml")
+)
 
-	log.Println("Starting YATR ...")
-	log.Println("")
+type command struct {
+	name string
+	help string
+	step yatr.Step
+}
 
-	conf, err := config.Read(".yatr.yml")
-	if err != nil {
-		log.Fatal("Error: ", err)
+var commands = []command{
+	{"run", "run the whole pipeline (default)", yatr.StepPublish},
+	{"configure", "run the pipeline until the configure step", yatr.StepConfigure},
+	{"task", "run the pipeline until the task step", yatr.StepTask},
+	{"collect", "run the pipeline until the collect step, without publishing", yatr.StepCollect},
+	{"detect", "show the runners and publishers detected for the targets, and why", 0},
+	{"targets", "list the targets declared in the config", 0},
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "usage: %s [flags] [command] [target ...]\n", os.Args[0])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.help)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "if the first argument is not a command, it is a target for the run command.")
+	fmt.Fprintln(w, "if no target is provided, the TARGET environment variable is used, and it may")
+	fmt.Fprintln(w, "contain several comma-separated targets.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "flags:")
+	flag.PrintDefaults()
+}
 
-	targetName, ok := os.LookupEnv("TARGET")
-	if !ok {
-		log.Fatalln("Error: Target not provided, export TARGET environment variable.")
+func getCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
+	return nil
+}
 
-	log.Println("    Target:   ", targetName)
+func listTargets(conf *yatr.Config) {
+	for _, name := range conf.TargetNames() {
+		fmt.Println(name)
+	}
+}
 
-	target := conf.Targets[targetName]
+func getTargetNames() []string {
+	targetNames := flag.Args()
+	if len(targetNames) > 0 {
+		return targetNames
+	}
 
-	dir, err := os.Getwd()
-	if err != nil {
-		log.Fatal("Error: ", err)
+	env, ok := os.LookupEnv("TARGET")
+	if !ok {
+		log.Fatalln("Error: Target not provided, pass it as argument or export TARGET environment variable.")
+	}
+	for _, name := range strings.Split(env, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			targetNames = append(targetNames, name)
+		}
 	}
+	if len(targetNames) == 0 {
+		log.Fatalln("Error: TARGET environment variable is empty.")
+	}
+	return targetNames
+}
+
+func printDetections(title string, detections []*yatr.Detection) {
+	fmt.Printf("    %s:\n", title)
+	for _, d := range detections {
+		result := "no"
+		if d.Detected {
+			result = "yes"
+		}
+		if d.Evidence != "" {
+			result += " (" + d.Evidence + ")"
+		}
+		fmt.Printf("        %-15s %s\n", d.Name+":", result)
+	}
+}
+
+func detect(conf *yatr.Config, srcDir string, targetNames []string) {
+	for _, name := range targetNames {
+		d, err := yatr.Detect(conf, srcDir, name)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
 
-	run, ctx := runners.Get(targetName, dir, path.Join(dir, "build"))
-	if run == nil || ctx == nil {
-		log.Fatal("Error: No runner found for this project!")
+		fmt.Println("Target:", d.Target)
+		printDetections("Runners", d.Runners)
+		switch {
+		case d.RunnerUnknown:
+			fmt.Printf("    Runner: %s (configured, unknown runner)\n", d.Runner)
+		case d.RunnerConfigured:
+			fmt.Printf("    Runner: %s (configured)\n", d.Runner)
+		case d.Runner != "":
+			fmt.Printf("    Runner: %s (first detected)\n", d.Runner)
+		default:
+			fmt.Println("    Runner: (none detected)")
+		}
+		printDetections("Publishers", d.Publishers)
+		if d.Publisher != "" {
+			fmt.Printf("    Publisher: %s (first detected)\n", d.Publisher)
+		} else {
+			fmt.Println("    Publisher: (none detected)")
+		}
+		fmt.Println()
 	}
-	log.Println("    Runner:   ", run.Name())
 
-	pub, pubErr := publishers.Get(ctx)
-	if pubErr != nil {
-		log.Printf("    Publisher: (%s)", pubErr)
-	} else if pub != nil {
-		log.Println("    Publisher:", pub.Name())
+	env := yatr.DetectEnv()
+	fmt.Println("Environment:")
+	for _, e := range env.Evidence {
+		fmt.Println("    -", e)
+	}
+	if env.Error != "" {
+		fmt.Printf("    Publishing: %s\n", env.Error)
+	} else if env.Release {
+		fmt.Println("    Publishing: enabled, release")
 	} else {
-		log.Println("    Publisher: (not available)")
+		fmt.Println("    Publishing: enabled")
 	}
+}
 
-	log.Println("")
-	log.Println("    Source directory:", ctx.SrcDir)
-	log.Println("    Build directory: ", ctx.BuildDir)
-	log.Println("")
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("[YATR] >>> ")
 
-	log.Println("Step: Git repository unshallow")
-	if err := git.Unshallow(ctx.SrcDir); err != nil {
-		log.Fatal("Error: ", err)
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd := getCommand("run")
+	if flag.NArg() > 0 {
+		if c := getCommand(flag.Arg(0)); c != nil {
+			cmd = c
+
+			// flags are also accepted after the command name
+			flag.CommandLine.Parse(flag.Args()[1:])
+		}
 	}
-	log.Println("")
 
-	configureArgs := append(conf.DefaultConfigureArgs, target.ConfigureArgs...)
+	confName := *confFile
+	if confName == "" {
+		confName = os.Getenv("YATR_CONFIG")
+	}
 
-	log.Printf("Step: Configure (Runner: %s)\n", run.Name())
-	proj, err := run.Configure(ctx, configureArgs)
+	// only the default config file is optional
+	confRequired := confName != ""
+	if !confRequired {
+		confName = ".yatr.yml"
+	}
+
+	conf, err := yatr.ReadConfig(confName, confRequired)
 	if err != nil {
 		log.Fatal("Error: ", err)
 	}
-	log.Println("")
 
-	tmpl := template.New("task-args")
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
 
-	taskArgs := append(conf.DefaultTaskArgs, target.TaskArgs...)
-	finalTaskArgs := []string{}
-	for _, arg := range taskArgs {
-		t, err := tmpl.Parse(arg)
-		if err != nil {
-			log.Fatal("Error: ", err)
-		}
+	if err := conf.Validate(); err != nil {
+		log.Fatal("Error: ", err)
+	}
 
-		b := new(bytes.Buffer)
-		if err := t.Execute(b, proj); err != nil {
-			log.Fatal("Error: ", err)
-		}
+	if cmd.name == "targets" {
+		listTargets(conf)
+		return
+	}
 
-		finalTaskArgs = append(finalTaskArgs, b.String())
+	if cmd.name == "detect" {
+		detect(conf, dir, getTargetNames())
+		return
 	}
 
-	log.Printf("Step: Task (Runner: %s)\n", run.Name())
-	var taskErr error
-	if len(target.TaskScript) > 0 {
-		taskErr = runners.RunTargetScript(ctx, proj, target.TaskScript, finalTaskArgs)
-	} else {
-		taskErr = run.Task(ctx, proj, finalTaskArgs)
+	if *jsonPlan && !*dryRun {
+		fmt.Fprint(flag.CommandLine.Output(), "-json requires -dry-run\n\n")
+		usage()
+		os.Exit(2)
+	}
+
+	if *dryRun {
+		yatr.EnableDryRun()
 	}
+
+	targetNames := getTargetNames()
+
+	log.Println("Starting YATR ...")
 	log.Println("")
-	if taskErr != nil && !target.PublishOnFailure {
-		log.Fatal("Error: ", taskErr)
+
+	for _, name := range targetNames {
+		if !conf.HasTarget(name) {
+			log.Printf("Warning: Target %q is not declared in config, declared targets: %s", name, strings.Join(conf.TargetNames(), ", "))
+			log.Println("")
+		}
 	}
 
-	log.Printf("Step: Collect (Runner: %s)\n", run.Name())
-	archives, err := run.Collect(ctx, proj, finalTaskArgs)
+	p := &yatr.Pipeline{
+		Config:   conf,
+		SrcDir:   dir,
+		Last:     cmd.step,
+		Parallel: *parallel,
+	}
+	rep, err := p.Run(targetNames)
 	if err != nil {
-		log.Println("Warning: ", err)
+		log.Fatal("Error: ", err)
 	}
-	log.Println("")
-
-	archives = fs.CheckArchives(ctx.BuildDir, archives)
 
-	if len(target.ArchiveFilter) > 0 {
-		archives = fs.FilterArchives(archives, target.ArchiveFilter)
+	if *dryRun {
+		if err := yatr.WritePlan(os.Stdout, *jsonPlan); err != nil {
+			log.Fatal("Error: ", err)
+		}
 	}
 
-	if len(archives) > 0 {
-		log.Println("Build details:")
-		log.Println("")
-		log.Println("    Project Name:   ", proj.Name)
-		log.Println("    Project Version:", proj.Version)
-		log.Println("    Archives:")
-		for _, archive := range archives {
-			log.Println("        -", archive)
+	if len(rep.Targets) == 1 {
+		if !rep.Targets[0].Success {
+			log.Fatal("Error: ", rep.Targets[0].Error)
 		}
-		log.Println("")
+		return
+	}
 
-		if pubErr != nil {
-			log.Printf("Step: Publish: (%s)", pubErr)
+	failed := 0
+	log.Println("Summary:")
+	log.Println("")
+	for _, t := range rep.Targets {
+		duration := time.Duration(t.Duration * float64(time.Second)).Round(time.Second)
+		if !t.Success {
+			failed++
+			log.Printf("    %s: FAILED (%s): %s", t.Target, duration, t.Error)
 		} else {
-			log.Printf("Step: Publish (Publisher: %s)\n", pub.Name())
-			if err := pub.Publish(ctx, proj, archives, target.ArchiveExtractFilter); err != nil {
-				log.Fatal("Error: ", err)
-			}
+			log.Printf("    %s: OK (%s)", t.Target, duration)
 		}
-	} else {
-		log.Println("Step: Publish (disabled, no archives to upload)")
 	}
-
 	log.Println("")
-	if taskErr != nil {
-		log.Println("!!! TASK FAILED !!!")
-		log.Println()
-		log.Fatal("Error: ", taskErr)
-	} else {
-		log.Println("All done! \\o/")
+
+	if failed > 0 {
+		log.Fatalf("Error: %d of %d targets failed", failed, len(rep.Targets))
 	}
 }
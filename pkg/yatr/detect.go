@@ -0,0 +1,101 @@
+package yatr
+
+import (
+	"path/filepath"
+
+	"github.com/rafaelmartins/yatr/internal/publishers"
+	"github.com/rafaelmartins/yatr/internal/runners"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+type Detection = types.Detection
+
+// TargetDetection describes how the runner and publisher of a target are
+// chosen. Runner is the runner that would be used, that is the configured
+// one if RunnerConfigured is set, otherwise the first runner detected.
+// RunnerUnknown is set if the configured runner does not exist.
+// Publisher is the first publisher detected, that is only used if the
+// environment allows publishing.
+type TargetDetection struct {
+	Target           string
+	Runners          []*Detection
+	Runner           string
+	RunnerConfigured bool
+	RunnerUnknown    bool
+	Publishers       []*Detection
+	Publisher        string
+}
+
+// EnvDetection describes the environment checks done before publishing.
+// Error is set if publishing is disabled.
+type EnvDetection struct {
+	Release  bool
+	Evidence []string
+	Error    string
+}
+
+// Detect evaluates all the runners and publishers for a target, without
+// running anything.
+func Detect(conf *Config, srcDir string, targetName string) (*TargetDetection, error) {
+	target, err := conf.GetTarget(targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &types.Ctx{
+		TargetName: targetName,
+		SrcDir:     srcDir,
+		BuildDir:   filepath.Join(srcDir, "build", targetName),
+	}
+
+	rv := &TargetDetection{
+		Target:     targetName,
+		Runners:    runners.Detect(ctx, target.TaskScript != "", runnerOptions(conf)),
+		Publishers: publishers.Detect(ctx),
+	}
+
+	rv.Runner = target.Runner
+	if rv.Runner == "" {
+		rv.Runner = conf.Runner
+	}
+	rv.RunnerConfigured = rv.Runner != ""
+
+	if rv.RunnerConfigured {
+		rv.RunnerUnknown = true
+		for _, d := range rv.Runners {
+			if d.Name == rv.Runner {
+				rv.RunnerUnknown = false
+				break
+			}
+		}
+	} else {
+		for _, d := range rv.Runners {
+			if d.Detected {
+				rv.Runner = d.Name
+				break
+			}
+		}
+	}
+
+	for _, d := range rv.Publishers {
+		if d.Detected {
+			rv.Publisher = d.Name
+			break
+		}
+	}
+
+	return rv, nil
+}
+
+// DetectEnv evaluates the environment checks done before publishing.
+func DetectEnv() *EnvDetection {
+	release, evidence, err := publishers.CheckEnv()
+	rv := &EnvDetection{
+		Release:  release,
+		Evidence: evidence,
+	}
+	if err != nil {
+		rv.Error = err.Error()
+	}
+	return rv
+}
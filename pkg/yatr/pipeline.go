@@ -0,0 +1,469 @@
+package yatr
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rafaelmartins/yatr/internal/config"
+	"github.com/rafaelmartins/yatr/internal/fs"
+	"github.com/rafaelmartins/yatr/internal/git"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/publishers"
+	"github.com/rafaelmartins/yatr/internal/report"
+	"github.com/rafaelmartins/yatr/internal/runners"
+	"github.com/rafaelmartins/yatr/internal/tmpl"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+// Step is the last step executed by a pipeline.
+type Step int
+
+const (
+	StepConfigure Step = iota + 1
+	StepTask
+	StepCollect
+	StepPublish
+)
+
+// Pipeline runs targets from a config. The zero value of each field is
+// usable: the current directory is the source directory, the whole pipeline
+// is executed and targets run serially. If Parallel is set, the configure
+// steps, that may modify the source directory, still run serially. Then the
+// other steps of the targets with a ParallelSafe runner run in parallel,
+// followed by the other targets, serially.
+type Pipeline struct {
+	Config   *Config
+	SrcDir   string
+	Last     Step
+	Parallel bool
+}
+
+// Run runs the pipeline for targetNames. Failed targets are reported in the
+// returned report, the error is only set if the pipeline itself failed. A
+// build/yatr-report.json file is written to the source directory, except
+// on dry-run.
+func (p *Pipeline) Run(targetNames []string) (*Report, error) {
+	conf := p.Config
+	if conf == nil {
+		conf = &Config{}
+	}
+
+	dir := p.SrcDir
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	last := p.Last
+	if last == 0 {
+		last = StepPublish
+	}
+
+	rep := &report.Report{
+		StartedAt: time.Now().UTC(),
+		Targets:   []*report.Target{},
+	}
+
+	err := runTargets(conf, dir, targetNames, last, p.Parallel, rep)
+
+	rep.Duration = time.Since(rep.StartedAt).Seconds()
+	rep.Success = err == nil
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	for _, t := range rep.Targets {
+		if !t.Success {
+			rep.Success = false
+		}
+	}
+
+	if !plan.Enabled() {
+		if err := rep.Write(filepath.Join(dir, "build", "yatr-report.json")); err != nil {
+			log.Println("Warning: failed to write build report: ", err)
+		}
+	}
+
+	return rep, err
+}
+
+func runTargets(conf *config.Config, srcDir string, targetNames []string, last Step, parallel bool, rep *report.Report) error {
+	log.Println("Step: Git repository unshallow")
+	if err := git.Unshallow(srcDir); err != nil {
+		return err
+	}
+	log.Println("")
+
+	targets := make([]*report.Target, len(targetNames))
+	starts := make([]time.Time, len(targetNames))
+	configuredTargets := make([]*configuredTarget, len(targetNames))
+
+	done := func(i int, err error) {
+		if err != nil && len(targetNames) > 1 {
+			log.Printf("Error: %s: %s", targetNames[i], err)
+		}
+
+		t := targets[i]
+		t.Duration = time.Since(starts[i]).Seconds()
+		t.Success = err == nil
+		if err != nil {
+			t.Error = err.Error()
+		}
+	}
+
+	configureOne := func(i int) error {
+		plan.SetTarget(targetNames[i])
+
+		targets[i] = &report.Target{
+			Target:   targetNames[i],
+			Archives: []*report.Archive{},
+		}
+		starts[i] = time.Now()
+
+		var err error
+		configuredTargets[i], err = configureTarget(conf, srcDir, targetNames[i], targets[i])
+		return err
+	}
+
+	finishOne := func(i int) {
+		plan.SetTarget(targetNames[i])
+		done(i, finishTarget(conf, srcDir, configuredTargets[i], last, targets[i]))
+	}
+
+	// dry-run records operations per target, that requires running serially
+	if parallel && !plan.Enabled() {
+
+		// configure steps may modify the source directory, they run serially
+		// before any other step
+		for i := range targetNames {
+			if err := configureOne(i); err != nil {
+				done(i, err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		serial := []int{}
+		for i := range targetNames {
+			if configuredTargets[i] == nil {
+				continue
+			}
+			if run := configuredTargets[i].run; !runners.IsParallelSafe(run) {
+				serial = append(serial, i)
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				finishOne(i)
+			}(i)
+		}
+		wg.Wait()
+
+		// these runners modify the source directory after configure
+		for _, i := range serial {
+			log.Printf("Target %s: runner %s is not parallel safe, running serially", targetNames[i], configuredTargets[i].run.Name())
+			finishOne(i)
+		}
+	} else {
+		for i := range targetNames {
+			if err := configureOne(i); err != nil {
+				done(i, err)
+				continue
+			}
+			finishOne(i)
+		}
+	}
+
+	rep.Targets = targets
+	return nil
+}
+
+func runHook(ctx *types.Ctx, proj *types.Project, data tmpl.Data, stage string, hook *config.Hook) error {
+	if hook == nil {
+		return nil
+	}
+
+	log.Printf("Step: Hook (%s)\n", stage)
+	defer log.Println("")
+
+	script, err := tmpl.Render(fmt.Sprintf("hooks.%s.script", stage), hook.Script, data)
+	if err != nil {
+		return err
+	}
+
+	args, err := tmpl.RenderAll(fmt.Sprintf("hooks.%s.args", stage), hook.Args, data)
+	if err != nil {
+		return err
+	}
+
+	if err := runners.RunTargetScript(ctx, proj, script, args); err != nil {
+		switch hook.OnFailure {
+		case "warn":
+			log.Printf("Warning: %s hook failed: %s", stage, err)
+		case "ignore":
+		default:
+			return fmt.Errorf("%s hook failed: %s", stage, err)
+		}
+	}
+	return nil
+}
+
+func runnerOptions(conf *config.Config) runners.Options {
+	return runners.Options{
+		Golang: conf.Golang,
+	}
+}
+
+// configuredTarget is a target that went through the configure step.
+type configuredTarget struct {
+	target *config.Target
+	run    runners.Runner
+	ctx    *types.Ctx
+	pub    publishers.Publisher
+	pubErr error
+	data   tmpl.Data
+	proj   *types.Project
+}
+
+func configureTarget(conf *config.Config, srcDir string, targetName string, rep *report.Target) (*configuredTarget, error) {
+	log.Println("    Target:   ", targetName)
+
+	target, err := conf.GetTarget(targetName)
+	if err != nil {
+		return nil, err
+	}
+	if err := target.Validate(srcDir); err != nil {
+		return nil, err
+	}
+
+	runnerName := target.Runner
+	if runnerName == "" {
+		runnerName = conf.Runner
+	}
+
+	run, ctx, err := runners.Get(targetName, srcDir, filepath.Join(srcDir, "build", targetName), runnerName, target.TaskScript != "", runnerOptions(conf))
+	if err != nil {
+		return nil, err
+	}
+	log.Println("    Runner:   ", run.Name())
+	rep.Runner = run.Name()
+
+	pub, pubErr := publishers.Get(ctx)
+	if pubErr != nil {
+		log.Printf("    Publisher: (%s)", pubErr)
+	} else if pub != nil {
+		log.Println("    Publisher:", pub.Name())
+		rep.Publisher = pub.Name()
+	} else {
+		log.Println("    Publisher: (not available)")
+	}
+
+	log.Println("")
+	log.Println("    Source directory:", ctx.SrcDir)
+	log.Println("    Build directory: ", ctx.BuildDir)
+	log.Println("")
+
+	data, err := tmpl.NewData(ctx, run.Name(), conf.TargetVariables(target))
+	if err != nil {
+		return nil, err
+	}
+
+	configureArgs, err := tmpl.RenderAll("configure_args", append(append([]string{}, conf.DefaultConfigureArgs...), target.ConfigureArgs...), data)
+	if err != nil {
+		return nil, err
+	}
+
+	// project is not known before configure
+	if err := runHook(ctx, &types.Project{}, data, "pre_configure", target.Hooks.PreConfigure); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Step: Configure (Runner: %s)\n", run.Name())
+	start := time.Now()
+	proj, err := run.Configure(ctx, configureArgs)
+	rep.Configure = report.NewStep(start, err)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("")
+
+	rep.ProjectName = proj.Name
+	rep.ProjectVersion = proj.Version
+	data.SetProject(proj)
+
+	if err := runHook(ctx, proj, data, "post_configure", target.Hooks.PostConfigure); err != nil {
+		return nil, err
+	}
+
+	return &configuredTarget{
+		target: target,
+		run:    run,
+		ctx:    ctx,
+		pub:    pub,
+		pubErr: pubErr,
+		data:   data,
+		proj:   proj,
+	}, nil
+}
+
+func finishTarget(conf *config.Config, srcDir string, c *configuredTarget, last Step, rep *report.Target) error {
+	target, run, ctx, pub, pubErr, data, proj := c.target, c.run, c.ctx, c.pub, c.pubErr, c.data, c.proj
+
+	if last == StepConfigure {
+		log.Println("Project details:")
+		log.Println("")
+		log.Println("    Project Name:   ", proj.Name)
+		log.Println("    Project Version:", proj.Version)
+		log.Println("")
+		log.Println("Stopping after configure step")
+		return nil
+	}
+
+	finalTaskArgs, err := tmpl.RenderAll("task_args", append(append([]string{}, conf.DefaultTaskArgs...), target.TaskArgs...), data)
+	if err != nil {
+		return err
+	}
+
+	fields := []struct {
+		key   string
+		value *string
+	}{
+		{"task_script", &target.TaskScript},
+		{"archive_filter", &target.ArchiveFilter},
+		{"archive_extract_filter", &target.ArchiveExtractFilter},
+	}
+	for _, f := range fields {
+		if *f.value, err = tmpl.Render(f.key, *f.value, data); err != nil {
+			return err
+		}
+	}
+	if err := target.Validate(srcDir); err != nil {
+		return err
+	}
+
+	if err := runHook(ctx, proj, data, "pre_task", target.Hooks.PreTask); err != nil {
+		return err
+	}
+
+	log.Printf("Step: Task (Runner: %s)\n", run.Name())
+	var taskErr error
+	start := time.Now()
+	if len(target.TaskScript) > 0 {
+		taskErr = runners.RunTargetScript(ctx, proj, target.TaskScript, finalTaskArgs)
+	} else {
+		taskErr = run.Task(ctx, proj, finalTaskArgs)
+	}
+	rep.Task = report.NewStep(start, taskErr)
+	rep.TaskFailed = taskErr != nil
+	log.Println("")
+	if taskErr != nil && plan.Enabled() {
+		// commands were not executed, failures are expected
+		log.Println("Warning: ", taskErr)
+		taskErr = nil
+	}
+	if taskErr != nil && (!target.PublishOnFailure || last == StepTask) {
+		return taskErr
+	}
+
+	if taskErr == nil {
+		if err := runHook(ctx, proj, data, "post_task", target.Hooks.PostTask); err != nil {
+			return err
+		}
+	}
+
+	if last == StepTask {
+		log.Println("Stopping after task step")
+		return nil
+	}
+
+	if err := runHook(ctx, proj, data, "pre_collect", target.Hooks.PreCollect); err != nil {
+		return err
+	}
+
+	log.Printf("Step: Collect (Runner: %s)\n", run.Name())
+	start = time.Now()
+	archives, err := run.Collect(ctx, proj, finalTaskArgs)
+	rep.Collect = report.NewStep(start, err)
+	if err != nil {
+		log.Println("Warning: ", err)
+	}
+	log.Println("")
+
+	if err := runHook(ctx, proj, data, "post_collect", target.Hooks.PostCollect); err != nil {
+		return err
+	}
+
+	// archives are only planned on dry-run, they don't exist
+	if !plan.Enabled() {
+		archives = fs.CheckArchives(ctx.BuildDir, archives)
+	}
+
+	if len(target.ArchiveFilter) > 0 {
+		archives = fs.FilterArchives(archives, target.ArchiveFilter)
+	}
+
+	for _, archive := range archives {
+		if plan.Enabled() {
+			rep.Archives = append(rep.Archives, &report.Archive{Name: archive})
+			continue
+		}
+		a, err := report.NewArchive(ctx.BuildDir, archive)
+		if err != nil {
+			return err
+		}
+		rep.Archives = append(rep.Archives, a)
+	}
+
+	if len(archives) > 0 {
+		log.Println("Build details:")
+		log.Println("")
+		log.Println("    Project Name:   ", proj.Name)
+		log.Println("    Project Version:", proj.Version)
+		log.Println("    Archives:")
+		for _, archive := range archives {
+			log.Println("        -", archive)
+		}
+		log.Println("")
+
+		if last == StepCollect {
+			log.Println("Step: Publish (skipped, stopping after collect step)")
+		} else if pubErr != nil {
+			log.Printf("Step: Publish: (%s)", pubErr)
+		} else {
+			if err := runHook(ctx, proj, data, "pre_publish", target.Hooks.PrePublish); err != nil {
+				return err
+			}
+
+			log.Printf("Step: Publish (Publisher: %s)\n", pub.Name())
+			start = time.Now()
+			err := pub.Publish(ctx, proj, archives, target.ArchiveExtractFilter)
+			rep.Publish = report.NewStep(start, err)
+			if err != nil {
+				return err
+			}
+			rep.PublishedOnFailure = taskErr != nil
+
+			if err := runHook(ctx, proj, data, "post_publish", target.Hooks.PostPublish); err != nil {
+				return err
+			}
+		}
+	} else {
+		log.Println("Step: Publish (disabled, no archives to upload)")
+	}
+
+	log.Println("")
+	if taskErr != nil {
+		log.Println("!!! TASK FAILED !!!")
+		log.Println()
+		return taskErr
+	}
+
+	log.Println("All done! \\o/")
+	return nil
+}
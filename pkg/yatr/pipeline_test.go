@@ -0,0 +1,95 @@
+package yatr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testRunner struct {
+	steps []string
+}
+
+func (r *testRunner) Name() string {
+	return "test"
+}
+
+func (r *testRunner) Detect(ctx *Ctx) bool {
+	return false
+}
+
+func (r *testRunner) Configure(ctx *Ctx, args []string) (*Project, error) {
+	r.steps = append(r.steps, "configure")
+	return &Project{Name: "foo", Version: "1.0"}, nil
+}
+
+func (r *testRunner) Task(ctx *Ctx, proj *Project, args []string) error {
+	r.steps = append(r.steps, "task")
+	return ioutil.WriteFile(filepath.Join(ctx.BuildDir, args[0]), []byte("foo"), 0666)
+}
+
+func (r *testRunner) Collect(ctx *Ctx, proj *Project, args []string) ([]string, error) {
+	r.steps = append(r.steps, "collect")
+	return []string{args[0]}, nil
+}
+
+func TestPipelineConfigInCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yatr-pipeline-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := &testRunner{}
+	RegisterRunner(func() Runner { return run })
+
+	conf := &Config{
+		Golang: Golang{
+			VersionVar: "main.version",
+		},
+		Targets: map[string]Target{
+			"base": {
+				Runner:   "test",
+				TaskArgs: []string{"{{ .Target }}.txt"},
+			},
+			"dist": {
+				Extends: "base",
+			},
+		},
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := conf.GetTarget("dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Runner != "test" {
+		t.Errorf("unexpected runner: %q", target.Runner)
+	}
+
+	p := &Pipeline{
+		Config: conf,
+		SrcDir: dir,
+		Last:   StepCollect,
+	}
+	rep, err := p.Run([]string{"dist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Targets) != 1 || !rep.Targets[0].Success {
+		t.Fatalf("target failed: %+v", rep.Targets)
+	}
+	if len(run.steps) != 3 {
+		t.Errorf("unexpected steps: %v", run.steps)
+	}
+
+	archives := rep.Targets[0].Archives
+	if len(archives) != 1 || archives[0].Name != "dist.txt" {
+		t.Errorf("unexpected archives: %+v", archives)
+	}
+}
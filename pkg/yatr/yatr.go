@@ -0,0 +1,73 @@
+// Package yatr allows running the yatr pipeline from Go programs, and
+// registering custom runners and publishers in-process.
+package yatr
+
+import (
+	"io"
+
+	"github.com/rafaelmartins/yatr/internal/config"
+	"github.com/rafaelmartins/yatr/internal/plan"
+	"github.com/rafaelmartins/yatr/internal/publishers"
+	"github.com/rafaelmartins/yatr/internal/report"
+	"github.com/rafaelmartins/yatr/internal/runners"
+	"github.com/rafaelmartins/yatr/internal/types"
+)
+
+type (
+	Runner        = runners.Runner
+	ParallelSafe  = runners.ParallelSafe
+	Publisher     = publishers.Publisher
+	Ctx           = types.Ctx
+	Project       = types.Project
+	Config        = config.Config
+	Target        = config.Target
+	Hook          = config.Hook
+	Hooks         = config.Hooks
+	Golang        = types.Golang
+	Report        = report.Report
+	TargetReport  = report.Target
+	StepReport    = report.Step
+	ArchiveReport = report.Archive
+)
+
+// ReadConfig reads a config file. If required is not set, a missing config
+// file is handled as an empty config.
+func ReadConfig(filename string, required bool) (*Config, error) {
+	return config.Read(filename, required)
+}
+
+// RegisterRunner adds a runner, that has precedence over the built-in
+// runners, but not over runner plugins. f must return a new instance on
+// each call, because runners keep state between steps.
+func RegisterRunner(f func() Runner) {
+	runners.Register(f)
+}
+
+// RegisterPublisher adds a publisher, that has precedence over the built-in
+// publishers, but not over publisher plugins.
+func RegisterPublisher(f func() Publisher) {
+	publishers.Register(f)
+}
+
+// RunnerNames returns the names of the runners available for srcDir.
+func RunnerNames(srcDir string) []string {
+	return runners.Names(srcDir)
+}
+
+// EnableDryRun makes all the pipelines record the operations they would
+// execute, instead of executing them. It can't be disabled.
+func EnableDryRun() {
+	plan.Enable()
+}
+
+func DryRun() bool {
+	return plan.Enabled()
+}
+
+// WritePlan writes the operations recorded on dry-run, as text or JSON.
+func WritePlan(w io.Writer, asJSON bool) error {
+	if asJSON {
+		return plan.WriteJSON(w)
+	}
+	return plan.WriteText(w)
+}